@@ -0,0 +1,313 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultContentDiffLimit is the largest size of files in
+	// bytes, for which content differences are shown, unless
+	// set otherwise in TreeCompareOptions
+	defaultContentDiffLimit = 64 << 10
+
+	// diffContext is the number of unchanged lines around
+	// changes in a unified diff
+	diffContext = 3
+
+	// diffCells caps the size of the table used to find
+	// common lines, above which differing regions are
+	// reported as replaced as a whole
+	diffCells = 1 << 22
+
+	// hexRows is the number of 16-byte rows shown in a hex
+	// dump around the first differing offset
+	hexRows = 4
+)
+
+// contentDiff reports how the content of two regular files
+// differs: as a unified line diff if both files are text,
+// or as a hex dump around the first differing byte otherwise.
+// Files larger than the limit are not read.
+func contentDiff(f Fatalfable, left, right *FileInfoPath, limit int64) string {
+
+	if limit <= 0 || !left.Mode().IsRegular() || !right.Mode().IsRegular() {
+		return ""
+	}
+
+	if left.Size() > limit || right.Size() > limit {
+		return fmt.Sprintf("content not shown, larger than %d bytes\n", limit)
+	}
+
+	l, err := ioutil.ReadFile(left.Path())
+	if err != nil {
+		f.Fatalf("Reading file %q: %s", left.Path(), err)
+	}
+
+	r, err := ioutil.ReadFile(right.Path())
+	if err != nil {
+		f.Fatalf("Reading file %q: %s", right.Path(), err)
+	}
+
+	if isText(l) && isText(r) {
+		return lineDiff(strings.SplitAfter(string(l), "\n"), strings.SplitAfter(string(r), "\n"))
+	}
+
+	return hexDiff(l, r)
+}
+
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// diffOp is a line in a diff, with indexes of the left and
+// right lines at the point where the operation applies
+type diffOp struct {
+	kind byte
+	line string
+	a, b int
+}
+
+// lineDiff produces a unified diff of two slices of lines,
+// as split by strings.SplitAfter
+func lineDiff(left, right []string) string {
+
+	if len(left) > 0 && left[len(left)-1] == "" {
+		left = left[:len(left)-1]
+	}
+	if len(right) > 0 && right[len(right)-1] == "" {
+		right = right[:len(right)-1]
+	}
+
+	pre := 0
+	for pre < len(left) && pre < len(right) && left[pre] == right[pre] {
+		pre++
+	}
+
+	suf := 0
+	for suf < len(left)-pre && suf < len(right)-pre && left[len(left)-1-suf] == right[len(right)-1-suf] {
+		suf++
+	}
+
+	ops := make([]diffOp, 0, len(left)+len(right))
+	for i := 0; i < pre; i++ {
+		ops = append(ops, diffOp{' ', left[i], i, i})
+	}
+
+	ops = append(ops, middleOps(left[pre:len(left)-suf], right[pre:len(right)-suf], pre)...)
+
+	for i := suf; i > 0; i-- {
+		ops = append(ops, diffOp{' ', left[len(left)-i], len(left) - i, len(right) - i})
+	}
+
+	var out strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		writeHunk(&out, ops[start:end])
+		k = end
+	}
+
+	return out.String()
+}
+
+// middleOps finds the longest common subsequence of lines
+// in the differing regions and lists the edit operations.
+// The offset is the number of common lines before the regions.
+func middleOps(left, right []string, offset int) []diffOp {
+
+	n, m := len(left), len(right)
+	ops := make([]diffOp, 0, n+m)
+
+	if n*m > diffCells {
+		for i, line := range left {
+			ops = append(ops, diffOp{'-', line, offset + i, offset})
+		}
+		for j, line := range right {
+			ops = append(ops, diffOp{'+', line, offset + n, offset + j})
+		}
+		return ops
+	}
+
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case left[i] == right[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < n || j < m; {
+		switch {
+		case i < n && j < m && left[i] == right[j]:
+			ops = append(ops, diffOp{' ', left[i], offset + i, offset + j})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', right[j], offset + i, offset + j})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', left[i], offset + i, offset + j})
+			i++
+		}
+	}
+
+	return ops
+}
+
+func writeHunk(out *strings.Builder, ops []diffOp) {
+
+	var aCount, bCount int
+	for _, op := range ops {
+		if op.kind != '+' {
+			aCount++
+		}
+		if op.kind != '-' {
+			bCount++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ops[0].a, aCount), hunkRange(ops[0].b, bCount))
+
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(strings.TrimSuffix(op.line, "\n"))
+		out.WriteByte('\n')
+		if !strings.HasSuffix(op.line, "\n") {
+			out.WriteString("\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// hexDiff dumps rows of both byte slices around the first
+// differing offset, marking the rows which differ
+func hexDiff(left, right []byte) string {
+
+	off := 0
+	for off < len(left) && off < len(right) && left[off] == right[off] {
+		off++
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "first difference at offset %d (%#x)\n", off, off)
+
+	from := off&^15 - 16
+	if from < 0 {
+		from = 0
+	}
+
+	for row := from; row < from+16*hexRows; row += 16 {
+		l, r := hexWindow(left, row), hexWindow(right, row)
+
+		switch {
+		case l == nil && r == nil:
+			return out.String()
+		case bytes.Equal(l, r):
+			out.WriteString(" " + hexRow(row, l))
+		default:
+			if l != nil {
+				out.WriteString("-" + hexRow(row, l))
+			}
+			if r != nil {
+				out.WriteString("+" + hexRow(row, r))
+			}
+		}
+	}
+
+	return out.String()
+}
+
+func hexWindow(data []byte, row int) []byte {
+	if row >= len(data) {
+		return nil
+	}
+	end := row + 16
+	if end > len(data) {
+		end = len(data)
+	}
+	return data[row:end]
+}
+
+func hexRow(offset int, data []byte) string {
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%08x  ", offset)
+
+	for i := 0; i < 16; i++ {
+		if i < len(data) {
+			fmt.Fprintf(&out, "%02x ", data[i])
+		} else {
+			out.WriteString("   ")
+		}
+		if i == 7 {
+			out.WriteByte(' ')
+		}
+	}
+
+	out.WriteString(" |")
+	for _, c := range data {
+		if c < 32 || c > 126 {
+			c = '.'
+		}
+		out.WriteByte(c)
+	}
+	out.WriteString("|\n")
+
+	return out.String()
+}
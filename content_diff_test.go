@@ -0,0 +1,121 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+
+	left := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	right := "1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+
+	expected := `@@ -1,7 +1,7 @@
+ 1
+ 2
+ 3
+-4
++four
+ 5
+ 6
+ 7
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+\ No newline at end of file
+`
+
+	diff := lineDiff(strings.SplitAfter(left, "\n"), strings.SplitAfter(right, "\n"))
+	if diff != expected {
+		t.Errorf("Expected the diff:\n%s\ngot:\n%s", expected, diff)
+	}
+
+	if diff := lineDiff(strings.SplitAfter(left, "\n"), strings.SplitAfter(left, "\n")); diff != "" {
+		t.Errorf("Expected no diff of the same lines, got:\n%s", diff)
+	}
+}
+
+func TestHexDiff(t *testing.T) {
+
+	left := []byte("0123456789abcdef0123456789abcdef0123456789abcdef\x00")
+	right := []byte("0123456789abcdef0123456789abcdef0123x56789abcdef\x01")
+
+	expected := `first difference at offset 36 (0x24)
+ 00000010  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|
+-00000020  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|
++00000020  30 31 32 33 78 35 36 37  38 39 61 62 63 64 65 66  |0123x56789abcdef|
+-00000030  00                                                |.|
++00000030  01                                                |.|
+`
+
+	if diff := hexDiff(left, right); diff != expected {
+		t.Errorf("Expected the diff:\n%s\ngot:\n%s", expected, diff)
+	}
+}
+
+func TestTreeCompareContent(t *testing.T) {
+
+	nodes := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/text", body: "one\ntwo\n"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/bin", body: "\x00\x01"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/text", body: "one\n2\n"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/bin", body: "\x00\x02"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	content := ContentRank(t)
+
+	result := TreeCompare(t, "a", "b", content)
+	if len(result.Entries) != 2 {
+		t.Fatalf("Expected two changed files, got %v", result.Strings())
+	}
+
+	if !strings.Contains(result.Entries[0].Content, "first difference at offset 1") {
+		t.Errorf("Expected a hex dump for the binary file, got:\n%s", result.Entries[0].Content)
+	}
+
+	if !strings.Contains(result.Entries[1].Content, "-two\n+2\n") {
+		t.Errorf("Expected a line diff for the text file, got:\n%s", result.Entries[1].Content)
+	}
+
+	diffs := TreeDiff(t, "a", "b", content)
+	if len(diffs) != 1 || !strings.Contains(diffs[0], "text: content differ\n") || !strings.Contains(diffs[0], "    -two\n    +2\n") {
+		t.Errorf("Expected the line diff in the notes, got %v", diffs)
+	}
+
+	if !strings.Contains(diffs[0], "    first difference at offset 1") {
+		t.Errorf("Expected the hex dump in the notes, got %v", diffs)
+	}
+
+	result = TreeCompareWith(t, "a", "b", TreeCompareOptions{ContentDiffLimit: 4}, content)
+	if !strings.Contains(result.Entries[1].Content, "larger than 4 bytes") {
+		t.Errorf("Expected the content limit to apply, got:\n%s", result.Entries[1].Content)
+	}
+
+	result = TreeCompareWith(t, "a", "b", TreeCompareOptions{ContentDiffLimit: -1}, content)
+	if result.Entries[1].Content != "" {
+		t.Errorf("Expected no content shown with a negative limit, got:\n%s", result.Entries[1].Content)
+	}
+
+	// Ranks made by ContentRank, and chains of them, show
+	// content differences, regardless of their names
+	chained := Chained("chain", SizeRank, content)
+	result = TreeCompare(t, "a", "b", chained)
+	if len(result.Entries) != 2 || !strings.Contains(result.Entries[1].Content, "-two\n+2\n") {
+		t.Errorf("Expected a line diff for the chained comparator, got %v", result.Strings())
+	}
+
+	result = TreeCompare(t, "a", "b", Named("content", ByContent(t)))
+	if len(result.Entries) != 2 || result.Entries[1].Content != "" {
+		t.Errorf("Expected no content shown for a named comparator, got %v", result.Strings())
+	}
+}
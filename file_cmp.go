@@ -0,0 +1,81 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+// FileCmp is the signature of three-way comparators of two
+// *FileInfoPath structs. A comparator returns a negative
+// number if the "left" parameter is less than the "right"
+// parameter by the comparator's criteria, a positive number
+// if it is greater, and zero if they are equal.
+//
+// Unlike a chain of FileRank functions, a chain of FileCmp
+// functions consults the next comparator only when the
+// previous comparator finds the parameters equal.
+type FileCmp func(left, right *FileInfoPath) int
+
+// Cmp adapts a FileRank function, like ByName or BySize,
+// into a three-way comparator by applying it both ways
+func Cmp(rank FileRank) FileCmp {
+	return func(left, right *FileInfoPath) int {
+		if rank(left, right) {
+			return -1
+		}
+		if rank(right, left) {
+			return 1
+		}
+		return 0
+	}
+}
+
+// Chain combines comparators into one, which returns the
+// result of the first comparator finding the parameters
+// not equal, or zero if all comparators find them equal
+func Chain(cmps ...FileCmp) FileCmp {
+	return func(left, right *FileInfoPath) int {
+		for _, cmp := range cmps {
+			if c := cmp(left, right); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// ByChain adapts chained three-way comparators back into a
+// FileRank function. To pass it to TreeDiff under a name, see
+// Named, or see Chained for chaining ranks.
+func ByChain(cmps ...FileCmp) FileRank {
+	chain := Chain(cmps...)
+	return func(left, right *FileInfoPath) bool {
+		return chain(left, right) < 0
+	}
+}
+
+// Chained makes a Rank of the ranks' comparators chained the
+// same way as ByChain does, with the given name. It shows how
+// the content differs, and has files hashed concurrently, if
+// any of the ranks does.
+func Chained(name string, ranks ...Rank) Rank {
+	cmps := make([]FileCmp, len(ranks))
+	chained := Rank{Name: name}
+	for i, rank := range ranks {
+		cmps[i] = Cmp(rank.Less)
+		chained.content = chained.content || rank.content
+		chained.hash = chained.hash || rank.hash
+	}
+	chained.Less = ByChain(cmps...)
+	return chained
+}
+
+// Compare applies provided FileRank comparators in order to
+// the pair of *FileInfoPath structs as a chain of three-way
+// comparators, see Cmp and Chain
+func Compare(left, right *FileInfoPath, ranks ...FileRank) int {
+	for _, rank := range ranks {
+		if c := Cmp(rank)(left, right); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
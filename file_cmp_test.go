@@ -0,0 +1,65 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+
+	files := []*Node{
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a", body: "long"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b", body: "s"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "c", body: "s"},
+	}
+
+	_, cleanup := TempCreateChdir(t, files)
+	defer cleanup()
+
+	fips := MakeFipSlice(t, "a", "b", "c")
+
+	bySize := Cmp(BySize)
+	if bySize(fips[0], fips[1]) <= 0 || bySize(fips[1], fips[0]) >= 0 || bySize(fips[1], fips[2]) != 0 {
+		t.Error("Adapted BySize does not order files by size")
+	}
+
+	sizeThenName := Chain(Cmp(BySize), Cmp(ByName))
+	if sizeThenName(fips[0], fips[1]) <= 0 {
+		t.Error("The chain consulted the name comparator despite different sizes")
+	}
+	if sizeThenName(fips[1], fips[2]) >= 0 {
+		t.Error("The chain did not consult the name comparator on equal sizes")
+	}
+	if sizeThenName(fips[2], fips[2]) != 0 {
+		t.Error("The chain ranked a file unequal to itself")
+	}
+
+	// "a" is less by name, but greater by size, so the
+	// earlier comparator decides
+	if Less(fips[0], fips[1], BySize, ByName) {
+		t.Error("Less returned true while the first comparator ranks the files the other way")
+	}
+	if !Less(fips[1], fips[0], BySize, ByName) || !Less(fips[1], fips[2], BySize, ByName) {
+		t.Error("Less did not order files by size, then name")
+	}
+
+	if Compare(fips[1], fips[2], BySize) != 0 || Compare(fips[1], fips[2], BySize, ByName) >= 0 {
+		t.Error("Compare did not apply comparators in order")
+	}
+
+	less := ByChain(sizeThenName)
+	if !less(fips[1], fips[0]) || less(fips[0], fips[1]) {
+		t.Error("ByChain does not follow the chain's order")
+	}
+
+	if attrs := rankNames(differingRanks(fips[0], fips[1], Named("size then name", less))); len(attrs) != 1 || attrs[0] != "size then name" {
+		t.Errorf("Expected the chain's rank name, got %v", attrs)
+	}
+
+	differing := differingRanks(fips[0], fips[1], Rank{Less: less})
+	if len(differing) != 1 || len(rankNames(differing)) != 0 {
+		t.Errorf("Expected an unnamed rank to tell files apart without a name, got %v", rankNames(differing))
+	}
+}
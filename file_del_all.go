@@ -31,3 +31,30 @@ func FileDelAll(f Fatalfable, root, name string) {
 		f.Fatalf("Removing %q: %q", root, err)
 	}
 }
+
+// FileDelMatch recursively removes filesystem objects selected
+// by the match function from the `root` directory. Matched
+// directories are removed with all their content.
+func FileDelMatch(f Fatalfable, root string, match PathMatch) {
+	err := filepath.Walk(
+		root,
+		func(p string, i os.FileInfo, err error) error {
+			if err != nil {
+				f.Fatalf("Remove: while walking to %q: %q", p, err)
+			}
+			if p == root {
+				return nil
+			}
+			skip, err := matchRel(match, root, p, i)
+			if skip {
+				if er := os.RemoveAll(p); er != nil {
+					f.Fatalf("Removing %q: %q", p, er)
+				}
+			}
+			return err
+		},
+	)
+	if err != nil {
+		f.Fatalf("Removing from %q: %q", root, err)
+	}
+}
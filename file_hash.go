@@ -0,0 +1,80 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// contentHash returns the SHA-256 hash of the file's content,
+// computing it only once for the FileInfoPath struct
+func (fip *FileInfoPath) contentHash() ([]byte, error) {
+	fip.hashOnce.Do(func() {
+		fip.hash, fip.hashErr = hashFile(fip.path)
+	})
+	return fip.hash, fip.hashErr
+}
+
+func hashFile(fn string) ([]byte, error) {
+
+	file, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("Opening the file %q: %s", fn, err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, fmt.Errorf("Hashing the file %q: %s", fn, err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// hashFiles computes content hashes of the regular files
+// concurrently with up to the given number of goroutines, or
+// with as many as there are CPUs if it is not positive. The
+// first encountered error is reported after all workers are
+// done.
+func hashFiles(f Fatalfable, fips []*FileInfoPath, workers int) {
+
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan *FileInfoPath)
+	errs := make(chan error, len(fips))
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fip := range jobs {
+				if _, err := fip.contentHash(); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	for _, fip := range fips {
+		if fip.Mode().IsRegular() {
+			jobs <- fip
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+	close(errs)
+
+	if err, ok := <-errs; ok {
+		f.Fatalf("%s", err)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+)
+
+type fileID struct {
+	dev, ino uint64
+}
+
+// inode is not supported on the platform, so hard links
+// are not detected
+func inode(fi os.FileInfo) (fileID, uint64, bool) {
+	return fileID{}, 0, false
+}
+
+// owner is not supported on the platform, so ownership is
+// not compared
+func owner(fi os.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,35 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"syscall"
+)
+
+type fileID struct {
+	dev, ino uint64
+}
+
+// inode returns the device and inode numbers of the file
+// described by the info, and the number of hard links to it
+func inode(fi os.FileInfo) (fileID, uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, 0, false
+	}
+	return fileID{uint64(st.Dev), uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// owner returns the user and group IDs of the file's owner
+func owner(fi os.FileInfo) (uid, gid uint32, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint32(st.Uid), uint32(st.Gid), true
+}
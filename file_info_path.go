@@ -5,23 +5,53 @@ package fst // import "go.didenko.com/fst"
 
 import (
 	"os"
+	"sync"
 )
 
-// FileInfoPath is a wrapper of os.FileInfo with an additional
-// field to store the path to the file of interest
+// FileInfoPath is a wrapper of os.FileInfo with additional
+// fields to store the path to the file of interest and,
+// for symbolic links, the link target. When collected
+// from a tree, it also has paths of hard links to the same
+// file in the tree.
 type FileInfoPath struct {
 	os.FileInfo
-	path string
+	path   string
+	target string
+	links  []string
+
+	hashOnce sync.Once
+	hash     []byte
+	hashErr  error
+
+	xattrOnce sync.Once
+	xattrs    map[string]string
+	xattrErr  error
 }
 
-// NewFileInfoPath creates new FileInfoPath struct
+// NewFileInfoPath creates new FileInfoPath struct. Symbolic
+// links are not followed, the info is about the link itself.
 func NewFileInfoPath(f Fatalfable, path string) *FileInfoPath {
-	fi, err := os.Stat(path)
+	fi, err := os.Lstat(path)
 	if err != nil {
 		f.Fatalf("While getting file %q info: %q", path, err)
 	}
 
-	return &FileInfoPath{fi, path}
+	return newFileInfoPath(f, fi, path)
+}
+
+func newFileInfoPath(f Fatalfable, fi os.FileInfo, path string) *FileInfoPath {
+
+	fip := &FileInfoPath{FileInfo: fi, path: path}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			f.Fatalf("While reading symlink %q: %q", path, err)
+		}
+		fip.target = target
+	}
+
+	return fip
 }
 
 // MakeFipSlice creates a slice of *FileInfoPaths based on
@@ -42,3 +72,19 @@ func MakeFipSlice(f Fatalfable, files ...string) []*FileInfoPath {
 func (fip *FileInfoPath) Path() string {
 	return fip.path
 }
+
+// Target returns the symbolic link target stored in the
+// FileInfoPath struct, or an empty string for other kinds
+// of filesystem objects
+func (fip *FileInfoPath) Target() string {
+	return fip.target
+}
+
+// HardLinks returns tree-relative paths of all hard links to
+// the same file, including itself, found in the same tree
+// by TreeDiff. It is empty if no other hard links to the file
+// were found in the tree, or if the FileInfoPath struct was
+// not collected from a tree.
+func (fip *FileInfoPath) HardLinks() []string {
+	return fip.links
+}
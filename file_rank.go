@@ -5,14 +5,15 @@ package fst // import "go.didenko.com/fst"
 
 import (
 	"bufio"
+	"bytes"
 	"os"
-	"testing"
+	"strings"
 	"time"
 )
 
 // FileRank is the signature of functions which are
-// provided to TreeDiff to compare two *FileInfoPath structs
-// and related files.
+// provided to TreeDiff as ranks to compare two *FileInfoPath
+// structs and related files.
 //
 // When comparing filesystem objects, the algorithm used in
 // the TreeDiff function expects the "less-than" logic from
@@ -26,8 +27,48 @@ import (
 // ByContent comparator generator for an example of it.
 type FileRank func(left, right *FileInfoPath) bool
 
-// ByName is basic for comparing directories and should
-// be provided as a first comparator in most cases
+// Rank is a FileRank comparator with an explicit name of
+// the attribute it compares, like "size" for BySize. The
+// names of ranks, which tell two objects apart, are listed
+// in TreeDiff notes and in the DiffEntry.Attrs field. Ranks
+// made by ContentRank or HashRank, or chained with them by
+// Chained, also show how the content differs for files told
+// apart by them. For ranks made by HashRank TreeCompare hashes
+// files concurrently before comparing them.
+type Rank struct {
+	Name    string
+	Less    FileRank
+	content bool
+	hash    bool
+}
+
+// Named makes a Rank of the comparator with the given name
+func Named(name string, less FileRank) Rank {
+	return Rank{Name: name, Less: less}
+}
+
+// Ranks of the comparators in this package, named after the
+// attributes they compare. See XattrRank and TimestampRank
+// for ranks of configurable comparators.
+var (
+	DirRank           = Named("dir", ByDir)
+	SizeRank          = Named("size", BySize)
+	TimeRank          = Named("time", ByTime)
+	TimeExactRank     = Named("time", ByTimeExact)
+	AtimeRank         = Named("atime", ByAtime)
+	PermRank          = Named("perm", ByPerm)
+	ModeRank          = Named("mode", ByMode)
+	TypeRank          = Named("type", ByType)
+	OwnerRank         = Named("owner", ByOwner)
+	NlinkRank         = Named("nlink", ByNlink)
+	SymlinkTargetRank = Named("link", BySymlinkTarget)
+	HardLinksRank     = Named("hard links", ByHardLinks)
+)
+
+// ByName compares base names of filesystem objects. It is
+// useful for sorting, see Less and Compare. TreeDiff and
+// TreeCompare pair objects by their relative paths, so ByName
+// never tells such a pair apart.
 func ByName(left, right *FileInfoPath) bool {
 	return left.Name() < right.Name()
 }
@@ -47,9 +88,114 @@ func BySize(left, right *FileInfoPath) bool {
 }
 
 // ByTime compares files' last modification times with up to
-// 10µs precision to accommodate filesyustem quirks
+// 10µs precision to accommodate filesyustem quirks. Symbolic
+// links are not compared, as their own timestamps are not
+// preserved by TreeCreate and TreeCopy. For other tolerances
+// and timestamps see ByTimestamp.
 func ByTime(left, right *FileInfoPath) bool {
-	return left.ModTime().Before(right.ModTime().Add(-10 * time.Microsecond))
+	return !isSymlink(left) &&
+		!isSymlink(right) &&
+		left.ModTime().Before(right.ModTime().Add(-10*time.Microsecond))
+}
+
+// ByTimeExact compares files' last modification times to
+// the nanosecond. It is meant for filesystems keeping
+// timestamps with nanoseconds, see TimePrecision. Symbolic
+// links are not compared.
+func ByTimeExact(left, right *FileInfoPath) bool {
+	return ByTimestamp(TimeCmp{Kind: ModTime})(left, right)
+}
+
+// ByAtime compares files' last access times with the same
+// precision as ByTime does. Symbolic links are not compared.
+// Access times are not compared on platforms where they are
+// not available. Note, that reading a file may update its
+// access time, depending on the filesystem's mount options.
+func ByAtime(left, right *FileInfoPath) bool {
+	return ByTimestamp(TimeCmp{Kind: AccessTime, Tolerance: 10 * time.Microsecond})(left, right)
+}
+
+// TimeKind selects which timestamp of filesystem objects
+// is compared by the ByTimestamp comparator
+type TimeKind int
+
+const (
+	// ModTime is the last modification time
+	ModTime TimeKind = iota
+
+	// AccessTime is the last access time, where available
+	AccessTime
+
+	// ChangeTime is the last status change time, where
+	// available. It is updated by the system on any change to
+	// a file, including its attributes, and cannot be set.
+	ChangeTime
+)
+
+// TimeCmp configures the ByTimestamp comparator. The Kind
+// selects the timestamp, and the Tolerance is the largest
+// difference of timestamps which are still ranked equal. With
+// SkipDirs set directories' timestamps are not compared, as
+// they change whenever files are added to or removed from
+// directories.
+type TimeCmp struct {
+	Kind      TimeKind
+	Tolerance time.Duration
+	SkipDirs  bool
+}
+
+// ByTimestamp returns a function which compares timestamps
+// of filesystem objects as configured. Same as with ByTime,
+// symbolic links are not compared. Access and status change
+// times are not compared on platforms where they are not
+// available.
+func ByTimestamp(tc TimeCmp) FileRank {
+	return func(left, right *FileInfoPath) bool {
+		if isSymlink(left) || isSymlink(right) {
+			return false
+		}
+
+		if tc.SkipDirs && (left.IsDir() || right.IsDir()) {
+			return false
+		}
+
+		leftT, ok := fileTime(left, tc.Kind)
+		if !ok {
+			return false
+		}
+
+		rightT, ok := fileTime(right, tc.Kind)
+		if !ok {
+			return false
+		}
+
+		return leftT.Before(rightT.Add(-tc.Tolerance))
+	}
+}
+
+// TimestampRank makes a Rank of the ByTimestamp comparator,
+// named "time", "atime", or "ctime" after the timestamp kind
+func TimestampRank(tc TimeCmp) Rank {
+	name := "time"
+	switch tc.Kind {
+	case AccessTime:
+		name = "atime"
+	case ChangeTime:
+		name = "ctime"
+	}
+	return Named(name, ByTimestamp(tc))
+}
+
+func fileTime(fip *FileInfoPath, kind TimeKind) (time.Time, bool) {
+	if kind == ModTime {
+		return fip.ModTime(), true
+	}
+
+	atime, ctime, ok := statTimes(fip)
+	if kind == AccessTime {
+		return atime, ok
+	}
+	return ctime, ok
 }
 
 // ByPerm compares bits 0-8 of Unix-like file permissions
@@ -57,23 +203,94 @@ func ByPerm(left, right *FileInfoPath) bool {
 	return left.Mode().Perm() < right.Mode().Perm()
 }
 
+// ByMode compares full file modes, including the file type,
+// the setuid, setgid, and sticky bits, and the permissions
+func ByMode(left, right *FileInfoPath) bool {
+	return left.Mode() < right.Mode()
+}
+
+// ByType compares types of filesystem objects, like regular
+// files, directories, or symbolic links, as encoded in the
+// os.ModeType bits of the file mode
+func ByType(left, right *FileInfoPath) bool {
+	return left.Mode()&os.ModeType < right.Mode()&os.ModeType
+}
+
+// ByOwner compares owners' user IDs of files, and then their
+// group IDs. Ownership is not compared on platforms where it
+// is not available.
+func ByOwner(left, right *FileInfoPath) bool {
+	leftU, leftG, ok := owner(left)
+	if !ok {
+		return false
+	}
+
+	rightU, rightG, ok := owner(right)
+	if !ok {
+		return false
+	}
+
+	return leftU < rightU || (leftU == rightU && leftG < rightG)
+}
+
+// ByNlink compares numbers of hard links to files, counting
+// the links both inside and outside of the compared trees.
+// Link counts are not compared on platforms where they are not
+// available.
+func ByNlink(left, right *FileInfoPath) bool {
+	_, leftN, ok := inode(left)
+	if !ok {
+		return false
+	}
+
+	_, rightN, ok := inode(right)
+	if !ok {
+		return false
+	}
+
+	return leftN < rightN
+}
+
+// BySymlinkTarget puts symbolic links earlier in a sort order
+// than other filesystem objects and compares targets of two
+// symbolic links as strings. Link targets are not followed.
+func BySymlinkTarget(left, right *FileInfoPath) bool {
+	if !isSymlink(left) {
+		return false
+	}
+	return !isSymlink(right) || left.Target() < right.Target()
+}
+
+// ByHardLinks compares the sets of hard links to files
+// within their respective trees, as collected by TreeDiff.
+// Files, which are hard-linked to the same tree-relative
+// paths in both trees, are ranked equal.
+func ByHardLinks(left, right *FileInfoPath) bool {
+	return strings.Join(left.HardLinks(), "\x00") < strings.Join(right.HardLinks(), "\x00")
+}
+
 // ByContent returns a function which compares files'
 // content without first comparing sizes. For example,
 // file containing "aaa" will rank as lesser than the one
 // containing "ab" even though it is opposite to their sizes.
 // To consider sizes first, make sure to specify the BySize
-// comparator earlier in the chain.
-func ByContent(t *testing.T) FileRank {
+// comparator earlier in the chain. Only regular files'
+// content is compared.
+func ByContent(f Fatalfable) FileRank {
 	return func(left, right *FileInfoPath) bool {
+		if !left.Mode().IsRegular() || !right.Mode().IsRegular() {
+			return false
+		}
+
 		leftF, err := os.Open(left.Path())
 		if err != nil {
-			t.Fatal(err)
+			f.Fatalf("Opening the file %q: %s", left.Path(), err)
 		}
 		defer leftF.Close()
 
 		rightF, err := os.Open(right.Path())
 		if err != nil {
-			t.Fatal(err)
+			f.Fatalf("Opening the file %q: %s", right.Path(), err)
 		}
 		defer rightF.Close()
 
@@ -104,12 +321,91 @@ func ByContent(t *testing.T) FileRank {
 	}
 }
 
-// Less applies provided comparators to the pair of *FileInfoPath structs.
-func Less(left, right *FileInfoPath, cmps ...FileRank) bool {
-	for _, less := range cmps {
-		if less(left, right) {
-			return true
+// ContentRank makes a Rank of the ByContent comparator,
+// named "content", which shows how the content differs for
+// files told apart by it
+func ContentRank(f Fatalfable) Rank {
+	return Rank{Name: "content", Less: ByContent(f), content: true}
+}
+
+// ByXattr returns a function which compares user extended
+// attributes of filesystem objects, as sorted lists of names
+// and values. The attributes of each object are read only
+// once, and are kept with the FileInfoPath struct. Symbolic
+// links are not compared. Objects on
+// filesystems not supporting extended attributes are treated
+// as having none.
+func ByXattr(f Fatalfable) FileRank {
+	return func(left, right *FileInfoPath) bool {
+		if isSymlink(left) || isSymlink(right) {
+			return false
 		}
+		return xattrKey(f, left) < xattrKey(f, right)
 	}
-	return false
+}
+
+// XattrRank makes a Rank of the ByXattr comparator, named
+// "xattr"
+func XattrRank(f Fatalfable) Rank {
+	return Named("xattr", ByXattr(f))
+}
+
+func xattrKey(f Fatalfable, fip *FileInfoPath) string {
+
+	attrs, err := fip.userXattrs()
+	if err != nil {
+		f.Fatalf("Listing extended attributes of %q: %s", fip.Path(), err)
+	}
+
+	pairs := make([]string, 0, len(attrs))
+	for _, name := range sortedXattrs(attrs) {
+		pairs = append(pairs, name+"="+attrs[name])
+	}
+
+	return strings.Join(pairs, "\x00")
+}
+
+// ByHash returns a function which compares SHA-256 hashes of
+// files' content. Each file is read and hashed only once, and
+// the hash is kept with the FileInfoPath struct. TreeCompare
+// hashes files concurrently before comparing them with ranks
+// made by HashRank. The resulting order of files is arbitrary,
+// yet consistent, which is enough to tell files apart. Only
+// regular files' content is compared.
+func ByHash(f Fatalfable) FileRank {
+	return func(left, right *FileInfoPath) bool {
+		if !left.Mode().IsRegular() || !right.Mode().IsRegular() {
+			return false
+		}
+
+		leftH, err := left.contentHash()
+		if err != nil {
+			f.Fatalf("%s", err)
+		}
+
+		rightH, err := right.contentHash()
+		if err != nil {
+			f.Fatalf("%s", err)
+		}
+
+		return bytes.Compare(leftH, rightH) < 0
+	}
+}
+
+// HashRank makes a Rank of the ByHash comparator, named
+// "content". Same as with ContentRank, it shows how the content
+// differs for files told apart by it.
+func HashRank(f Fatalfable) Rank {
+	return Rank{Name: "content", Less: ByHash(f), content: true, hash: true}
+}
+
+func isSymlink(fip *FileInfoPath) bool {
+	return fip.Mode()&os.ModeSymlink != 0
+}
+
+// Less applies provided comparators to the pair of *FileInfoPath
+// structs in order. Later comparators are only consulted when
+// the earlier ones rank the structs equal, see Compare.
+func Less(left, right *FileInfoPath, cmps ...FileRank) bool {
+	return Compare(left, right, cmps...) < 0
 }
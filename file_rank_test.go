@@ -4,23 +4,26 @@
 package fst // import "go.didenko.com/fst"
 
 import (
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestByContent(t *testing.T) {
 
 	files := []*Node{
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "1_same_a", "a 1 b 2 c 3 d 4\n"},
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "1_same_b", "a 1 b 2 c 3 d 4\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "1_same_a", body: "a 1 b 2 c 3 d 4\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "1_same_b", body: "a 1 b 2 c 3 d 4\n"},
 
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "2_diff_a", "a 1 b 2 c 3 d 4\n"},
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "2_diff_b", "a 1 b 2 b 2\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "2_diff_a", body: "a 1 b 2 c 3 d 4\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "2_diff_b", body: "a 1 b 2 b 2\n"},
 
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "3_empty_a", ""},
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "3_empty_b", ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "3_empty_a", body: ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "3_empty_b", body: ""},
 
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "4_length_a", "a 1 b 2 c 3 d 4\n"},
-		&Node{0700, Rfc3339(t, "2017-11-08T23:11:17Z"), "4_length_b", "a 1 b 2 c"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "4_length_a", body: "a 1 b 2 c 3 d 4\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "4_length_b", body: "a 1 b 2 c"},
 	}
 
 	_, cleanup := TempCreateChdir(t, files)
@@ -60,3 +63,234 @@ func TestByContent(t *testing.T) {
 		t.Errorf("File %v is not ranked less than %v as expected\n", fips[7].Name(), fips[6].Name())
 	}
 }
+
+func TestByHash(t *testing.T) {
+
+	files := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/same", body: "a 1 b 2 c 3 d 4\n"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/diff", body: "a 1 b 2 c 3 d 4\n"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/empty", body: ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b/same", body: "a 1 b 2 c 3 d 4\n"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b/diff", body: "a 1 b 2 c 3 d 5\n"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b/empty", body: ""},
+	}
+
+	_, cleanup := TempCreateChdir(t, files)
+	defer cleanup()
+
+	less := ByHash(t)
+
+	fips := MakeFipSlice(t, "a/same", "b/same", "a/diff", "b/diff", "a/empty", "b/empty")
+
+	if less(fips[0], fips[1]) || less(fips[1], fips[0]) {
+		t.Errorf("Files with same content ranked as ordered\n")
+	}
+
+	if less(fips[2], fips[3]) == less(fips[3], fips[2]) {
+		t.Errorf("Files with different content ranked as equal\n")
+	}
+
+	if less(fips[4], fips[5]) || less(fips[5], fips[4]) {
+		t.Errorf("Empty files ranked as ordered\n")
+	}
+
+	hash := HashRank(t)
+	if !hash.hash || !Chained("chain", SizeRank, hash).hash {
+		t.Error("Ranks of ByHash are not marked for hashing in advance")
+	}
+
+	result := TreeCompareWith(t, "a", "b", TreeCompareOptions{HashWorkers: 2}, hash)
+	if len(result.Entries) != 1 || result.Entries[0].Path != "diff" || result.Entries[0].Content == "" {
+		t.Errorf("Expected only the \"diff\" file to differ with its content shown, got %v", result.Strings())
+	}
+
+	diffs := TreeDiff(t, "a", "b", SizeRank, hash)
+	if len(diffs) != 1 || !strings.Contains(diffs[0], "diff: content differ\n") || !strings.Contains(diffs[0], "    -a 1 b 2 c 3 d 4\n    +a 1 b 2 c 3 d 5\n") {
+		t.Errorf("Expected only the \"diff\" file content to differ in the notes, got %v", diffs)
+	}
+
+	fr := &fatalRecorder{}
+	missing := &FileInfoPath{FileInfo: fips[0].FileInfo, path: "a/missing"}
+	hashFiles(fr, []*FileInfoPath{fips[0], missing}, 2)
+	if len(fr.msgs) != 1 {
+		t.Errorf("Expected a hashing failure to be reported once, got %v", fr.msgs)
+	}
+}
+
+func TestByHardLinks(t *testing.T) {
+
+	tree := "2001-01-01T01:01:01Z\t0700\ta/\n" +
+		"2001-01-01T01:01:01Z\t0600\ta/one\tsame\n" +
+		"2001-01-01T01:01:01Z\t0600\ta/two\t=> a/one\n" +
+		"2001-01-01T01:01:01Z\t0600\ta/single\tsame\n" +
+		"2001-01-01T01:01:01Z\t0700\tb/\n" +
+		"2001-01-01T01:01:01Z\t0600\tb/one\tsame\n" +
+		"2001-01-01T01:01:01Z\t0600\tb/two\t=> b/one\n"
+
+	_, cleanup := TempCreateChdir(t, ParseReader(t, strings.NewReader(tree)))
+	defer cleanup()
+
+	listA := collectRelative(t, "a", nil)
+	listB := collectRelative(t, "b", nil)
+
+	if _, _, ok := inode(listA["one"]); !ok {
+		t.Skip("Hard links are not detected on the platform")
+	}
+
+	if links := listA["two"].HardLinks(); strings.Join(links, ",") != "one,two" {
+		t.Errorf("Expected hard links \"one,two\", got %v", links)
+	}
+
+	if ByHardLinks(listA["one"], listB["one"]) || ByHardLinks(listB["one"], listA["one"]) {
+		t.Error("Files with the same hard links ranked as ordered")
+	}
+
+	if !ByHardLinks(listA["single"], listA["one"]) || ByHardLinks(listA["one"], listA["single"]) {
+		t.Error("A file without hard links is not ranked before a hard-linked one")
+	}
+}
+
+func TestByTimestamp(t *testing.T) {
+
+	files := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/f", body: "f"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-09T23:11:17Z"), name: "b/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:18Z"), name: "b/f", body: "f"},
+	}
+
+	_, cleanup := TempCreateChdir(t, files)
+	defer cleanup()
+
+	fips := MakeFipSlice(t, "a", "b", "a/f", "b/f")
+
+	exact := ByTimestamp(TimeCmp{Kind: ModTime})
+	if !exact(fips[2], fips[3]) || exact(fips[3], fips[2]) {
+		t.Error("Files a second apart are not ordered by modification time")
+	}
+
+	loose := ByTimestamp(TimeCmp{Kind: ModTime, Tolerance: 2 * time.Second})
+	if loose(fips[2], fips[3]) || loose(fips[3], fips[2]) {
+		t.Error("Files a second apart are ordered despite the tolerance")
+	}
+
+	if !loose(fips[0], fips[1]) {
+		t.Error("Directories a day apart are not ordered by modification time")
+	}
+
+	noDirs := ByTimestamp(TimeCmp{Kind: ModTime, SkipDirs: true})
+	if noDirs(fips[0], fips[1]) || !noDirs(fips[2], fips[3]) {
+		t.Error("Only directories are expected to be skipped")
+	}
+
+	if _, _, ok := statTimes(fips[2]); ok {
+		access := ByTimestamp(TimeCmp{Kind: AccessTime})
+		if !access(fips[2], fips[3]) || access(fips[3], fips[2]) {
+			t.Error("Files a second apart are not ordered by access time")
+		}
+	}
+
+	if diffs := TreeDiff(t, "a", "b", TimestampRank(TimeCmp{Kind: ModTime, Tolerance: time.Second, SkipDirs: true})); diffs != nil {
+		t.Errorf("Trees with files within tolerance tested as different: %v", diffs)
+	}
+}
+
+func TestByModeTypeOwnerNlink(t *testing.T) {
+
+	files := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/d/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/f", body: "f"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "a/t", body: "t"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b/"},
+		&Node{perm: 0700, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b/d/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2017-11-08T23:11:17Z"), name: "b/f", body: "f"},
+		&Node{name: "b/t", link: "f"},
+	}
+
+	_, cleanup := TempCreateChdir(t, files)
+	defer cleanup()
+
+	if err := os.Chmod("b/d", 0700|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Link("b/f", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	fips := MakeFipSlice(t, "a/d", "b/d", "a/f", "b/f", "a/t", "b/t")
+
+	if ByPerm(fips[0], fips[1]) || ByPerm(fips[1], fips[0]) {
+		t.Error("ByPerm is expected to ignore the sticky bit")
+	}
+
+	if !ByMode(fips[0], fips[1]) || ByMode(fips[1], fips[0]) {
+		t.Error("ByMode does not tell the sticky bit apart")
+	}
+
+	if ByType(fips[0], fips[1]) || ByType(fips[1], fips[0]) {
+		t.Error("ByType tells directories apart by their special bits")
+	}
+
+	if !ByType(fips[4], fips[5]) && !ByType(fips[5], fips[4]) {
+		t.Error("ByType does not tell a file and a symlink apart")
+	}
+
+	if ByOwner(fips[2], fips[3]) || ByOwner(fips[3], fips[2]) {
+		t.Error("Files of the same owner are ranked as ordered")
+	}
+
+	if _, _, ok := inode(fips[2]); ok && (!ByNlink(fips[2], fips[3]) || ByNlink(fips[3], fips[2])) {
+		t.Error("ByNlink does not tell the link counts apart")
+	}
+
+	result := TreeCompare(t, "a", "b", ModeRank, TypeRank, OwnerRank, NlinkRank)
+
+	expected := map[string]string{
+		"d": "mode",
+		"f": "nlink",
+		"t": "mode,type",
+	}
+
+	if _, _, ok := inode(fips[2]); !ok {
+		delete(expected, "f")
+	}
+
+	if len(result.Entries) != len(expected) {
+		t.Fatalf("Expected %d changed entries, got %v", len(expected), result.Strings())
+	}
+
+	for _, e := range result.Entries {
+		if _, ok := expected[e.Path]; !ok || e.Kind != Changed {
+			t.Errorf("Unexpected %v entry for %q", e.Kind, e.Path)
+			continue
+		}
+		if strings.Join(e.Attrs, ",") != expected[e.Path] {
+			t.Errorf("Expected %q to differ in %q, got %v", e.Path, expected[e.Path], e.Attrs)
+		}
+	}
+
+	diffs := strings.Join(TreeDiff(t, "a", "b", ModeRank, TypeRank, OwnerRank, NlinkRank), "")
+	for path, attrs := range expected {
+		note := path + ": " + strings.Replace(attrs, ",", ", ", -1) + " differ\n"
+		if !strings.Contains(diffs, note) {
+			t.Errorf("Expected %q in the TreeDiff notes, got:\n%s", note, diffs)
+		}
+	}
+
+	if os.Getuid() != 0 {
+		return
+	}
+
+	if err := os.Lchown("b/f", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	result = TreeCompare(t, "a", "b", OwnerRank)
+	if len(result.Entries) != 1 || result.Entries[0].Path != "f" {
+		t.Errorf("Expected only the \"f\" file owner to differ, got %v", result.Strings())
+	}
+}
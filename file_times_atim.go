@@ -0,0 +1,23 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+//go:build dragonfly || linux || openbsd || solaris
+// +build dragonfly linux openbsd solaris
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// statTimes returns the access and status change times of
+// the file described by the info
+func statTimes(fi os.FileInfo) (atime, ctime time.Time, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.Unix(st.Atim.Unix()), time.Unix(st.Ctim.Unix()), true
+}
@@ -0,0 +1,23 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// statTimes returns the access and status change times of
+// the file described by the info
+func statTimes(fi os.FileInfo) (atime, ctime time.Time, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.Unix(st.Atimespec.Unix()), time.Unix(st.Ctimespec.Unix()), true
+}
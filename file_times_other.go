@@ -0,0 +1,18 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"time"
+)
+
+// statTimes is not supported on the platform, so access
+// and status change times are not compared
+func statTimes(fi os.FileInfo) (atime, ctime time.Time, ok bool) {
+	return time.Time{}, time.Time{}, false
+}
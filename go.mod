@@ -1,3 +1,5 @@
 module go.didenko.com/fst/v2
 
 go 1.12
+
+require github.com/BurntSushi/toml v0.3.1
@@ -0,0 +1,220 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// jsonNode is the JSON and TOML representation of a Node
+type jsonNode struct {
+	Time     string            `json:"time,omitempty" toml:"time,omitempty"`
+	Atime    string            `json:"atime,omitempty" toml:"atime,omitempty"`
+	Perm     string            `json:"perm,omitempty" toml:"perm,omitempty"`
+	Name     string            `json:"name" toml:"name"`
+	Content  string            `json:"content,omitempty" toml:"content,omitempty"`
+	Encoding string            `json:"encoding,omitempty" toml:"encoding,omitempty"`
+	File     string            `json:"file,omitempty" toml:"file,omitempty"`
+	Link     string            `json:"link,omitempty" toml:"link,omitempty"`
+	Hard     string            `json:"hard,omitempty" toml:"hard,omitempty"`
+	Xattrs   map[string]string `json:"xattrs,omitempty" toml:"xattrs,omitempty"`
+
+	XattrEncodings map[string]string `json:"xattr_encodings,omitempty" toml:"xattr_encodings,omitempty"`
+}
+
+// ParseJSON decodes a JSON tree description with the parser's
+// settings. See the ParseJSON function for the format.
+func (p Parser) ParseJSON(f Fatalfable, config io.Reader) []*Node {
+
+	var jns []*jsonNode
+
+	dec := json.NewDecoder(config)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&jns)
+	if err != nil {
+		f.Fatalf("Decoding the JSON tree description: %q", err)
+	}
+
+	return p.fromJSONNodes(f, "JSON", jns)
+}
+
+// fromJSONNodes converts decoded entries of a structured tree
+// description, reporting all problems found at once
+func (p Parser) fromJSONNodes(f Fatalfable, format string, jns []*jsonNode) []*Node {
+
+	if p.Now.IsZero() {
+		p.Now = time.Now()
+	}
+
+	nodes := make([]*Node, 0, len(jns))
+	errs := make([]string, 0)
+
+	for i, jn := range jns {
+		node, err := p.fromJSON(jn)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("entry %d, %q: %s", i, jn.Name, err))
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(errs) > 0 {
+		f.Fatalf("While parsing the %s tree description:\n%s", format, strings.Join(errs, "\n"))
+	}
+
+	return nodes
+}
+
+func (p Parser) fromJSON(jn *jsonNode) (*Node, error) {
+
+	if jn.Name == "" {
+		return nil, errors.New("the name is missing")
+	}
+
+	kinds := 0
+	for _, v := range []string{jn.Content, jn.File, jn.Link, jn.Hard} {
+		if v != "" {
+			kinds++
+		}
+	}
+
+	if kinds > 1 {
+		return nil, errors.New("only one of content, file, link, and hard can be set")
+	}
+
+	linked := jn.Link != "" || jn.Hard != ""
+
+	if linked && strings.HasSuffix(jn.Name, "/") {
+		return nil, errors.New("a directory can not be a link")
+	}
+
+	if strings.HasSuffix(jn.Hard, "/") {
+		return nil, errors.New("a hard link target can not be a directory")
+	}
+
+	node := &Node{name: jn.Name, link: jn.Link, hard: jn.Hard}
+
+	var err error
+
+	switch {
+	case jn.Time != "":
+		node.time, err = p.timestamp(jn.Time)
+		if err != nil {
+			return nil, err
+		}
+	case !linked:
+		return nil, errors.New("the time is missing")
+	}
+
+	if jn.Atime != "" {
+		if linked {
+			return nil, errors.New("an access time can not be set on links")
+		}
+		node.atime, err = p.timestamp(jn.Atime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case jn.Perm != "":
+		node.perm, err = permission(jn.Perm)
+		if err != nil {
+			return nil, err
+		}
+	case !linked:
+		return nil, errors.New("the permissions are missing")
+	}
+
+	if jn.File != "" {
+		node.from, err = p.resolve(jn.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	node.body = jn.Content
+	if jn.Encoding != "" {
+		node.body, err = decode(jn.Encoding, jn.Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(jn.Xattrs) > 0 && linked {
+		return nil, errors.New("extended attributes can not be set on links")
+	}
+
+	for name := range jn.XattrEncodings {
+		if _, ok := jn.Xattrs[name]; !ok {
+			return nil, fmt.Errorf("the encoded extended attribute %q is missing", name)
+		}
+	}
+
+	for name, value := range jn.Xattrs {
+		if err := xattrName(name); err != nil {
+			return nil, err
+		}
+
+		if encoding, ok := jn.XattrEncodings[name]; ok {
+			value, err = decode(encoding, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if node.xattrs == nil {
+			node.xattrs = make(map[string]string, len(jn.Xattrs))
+		}
+		node.xattrs[name] = value
+	}
+
+	return node, nil
+}
+
+// ParseJSON decodes a JSON tree description into a list
+// of filesystem node data, same as ParseReader does for the
+// tab-separated format. The JSON input is an array of objects
+// with the following fields:
+//
+// "time": time, in any of the ParseReader Field 1 formats
+//
+// "atime": optional access time, in any of the ParseReader
+// Field 1 formats, the same as "time" if omitted
+//
+// "perm": permissions, as in the ParseReader Field 2
+//
+// "name": the file or directory path, a directory path ends
+// with a forward slash
+//
+// "content": optional file content
+//
+// "encoding": optional encoding of the content, one of "base64",
+// "hex", or "gzip+base64"
+//
+// "file": optional path to a file to copy the content from
+//
+// "link": optional symbolic link target
+//
+// "hard": optional path to an earlier file to hard link to
+//
+// "xattrs": optional object with user extended attributes'
+// names and values, not allowed for links
+//
+// "xattr_encodings": optional object with names of extended
+// attributes, which values are encoded, and their encodings,
+// same as for the "encoding" field
+//
+// Only one of "content", "file", "link", and "hard" can be
+// set. Time and permissions are required except for links.
+// Unknown fields are errors.
+func ParseJSON(f Fatalfable, config io.Reader) []*Node {
+	return Parser{}.ParseJSON(f, config)
+}
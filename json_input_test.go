@@ -0,0 +1,110 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+
+	tree := `[
+  {"time": "2001-01-01T01:01:01Z", "perm": "0750", "name": "dir/"},
+  {"time": "2001-01-01T01:01:01Z", "perm": "0640", "name": "dir/text", "content": "two\nlines\n"},
+  {"time": "2001-01-01T01:01:01Z", "perm": "0600", "name": "dir/bin", "content": "AAFiaW5hcnn//g==", "encoding": "base64"},
+  {"time": "2001-01-01T01:01:01Z", "perm": "0600", "name": "dir/copy", "file": "testdata/parse_file_mocks/payloads/text.txt"},
+  {"name": "dir/link", "link": "text"},
+  {"name": "dir/hard", "hard": "dir/text"}
+]`
+
+	nodes := ParseJSON(t, strings.NewReader(tree))
+
+	expect := ParseReader(t, strings.NewReader(
+		"2001-01-01T01:01:01Z\t0750\tdir/\n"+
+			"2001-01-01T01:01:01Z\t0640\tdir/text\t\"two\\nlines\\n\"\n"+
+			"2001-01-01T01:01:01Z\t0600\tdir/bin\thex:000162696e617279fffe\n"+
+			"2001-01-01T01:01:01Z\t0600\tdir/copy\tfile:testdata/parse_file_mocks/payloads/text.txt\n"+
+			"2001-01-01T01:01:01Z\t0777\tdir/link\t-> text\n"+
+			"2001-01-01T01:01:01Z\t0777\tdir/hard\t=> dir/text\n"))
+
+	if len(nodes) != len(expect) {
+		t.Fatalf("Expected %d nodes, got %d", len(expect), len(nodes))
+	}
+
+	for i, n := range nodes {
+		if !sameNode(n, expect[i]) {
+			t.Errorf("Node mismatch, expected %+v, got %+v", expect[i], n)
+		}
+	}
+
+	var buf bytes.Buffer
+	WriteJSON(t, &buf, nodes)
+
+	for i, n := range ParseJSON(t, &buf) {
+		if !sameNode(n, nodes[i]) {
+			t.Errorf("Node changed in a round trip, expected %+v, got %+v", nodes[i], n)
+		}
+	}
+
+	bad := []string{
+		`[{"time": "2001-01-01T01:01:01Z", "perm": "0640"}]`,
+		`[{"perm": "0640", "name": "no_time"}]`,
+		`[{"time": "2001-01-01T01:01:01Z", "name": "no_perm"}]`,
+		`[{"time": "2001-01-01T01:01:01Z", "perm": "640", "name": "bad_perm"}]`,
+		`[{"time": "2001-01-01T01:01:01Z", "perm": "0640", "name": "f", "content": "x", "link": "y"}]`,
+		`[{"time": "2001-01-01T01:01:01Z", "perm": "0640", "name": "f", "content": "x", "encoding": "rot13"}]`,
+		`[{"name": "d/", "link": "y"}]`,
+		`[{"name": "f", "hard": "d/"}]`,
+		`[{"time": "2001-01-01T01:01:01Z", "perm": "0640", "name": "f", "owner": "root"}]`,
+	}
+
+	for _, tree := range bad {
+		fr := &fatalRecorder{}
+		ParseJSON(fr, strings.NewReader(tree))
+		if len(fr.msgs) == 0 {
+			t.Errorf("Malformed JSON tree description passed parsing: %s", tree)
+		}
+	}
+}
+
+func TestJSONBinaryXattrs(t *testing.T) {
+
+	nodes := []*Node{{
+		perm:   0600,
+		time:   Rfc3339(t, "2001-01-01T01:01:01Z"),
+		name:   "f",
+		xattrs: map[string]string{"user.bin": "\x00\xff\xfe", "user.text": "plain"},
+	}}
+
+	var buf bytes.Buffer
+	WriteJSON(t, &buf, nodes)
+
+	if !strings.Contains(buf.String(), `"user.bin": "AP/+"`) || !strings.Contains(buf.String(), `"user.text": "plain"`) {
+		t.Errorf("Unexpected encoding of extended attributes:\n%s", buf.String())
+	}
+
+	for _, n := range ParseJSON(t, &buf) {
+		if len(n.xattrs) != 2 || n.xattrs["user.bin"] != "\x00\xff\xfe" || n.xattrs["user.text"] != "plain" {
+			t.Errorf("Extended attributes changed in a round trip: %q", n.xattrs)
+		}
+	}
+
+	fr := &fatalRecorder{}
+	ParseJSON(fr, strings.NewReader(`[{"time": "2001-01-01T01:01:01Z", "perm": "0640", "name": "f", "xattr_encodings": {"user.x": "hex"}}]`))
+	if len(fr.msgs) == 0 {
+		t.Error("An encoding of a missing extended attribute passed parsing")
+	}
+}
+
+func sameNode(a, b *Node) bool {
+	linked := a.link != "" || a.hard != ""
+	return a.name == b.name &&
+		a.body == b.body &&
+		a.link == b.link &&
+		a.hard == b.hard &&
+		a.from == b.from &&
+		(linked || a.perm == b.perm && a.time.Equal(b.time))
+}
@@ -0,0 +1,72 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+func toJSON(n *Node) *jsonNode {
+
+	jn := &jsonNode{Name: n.name, File: n.from, Link: n.link, Hard: n.hard}
+
+	if n.link == "" && n.hard == "" {
+		jn.Time = n.time.Format(time.RFC3339Nano)
+		if !n.atime.IsZero() {
+			jn.Atime = n.atime.Format(time.RFC3339Nano)
+		}
+		jn.Perm = octal(n.perm)
+	}
+
+	if utf8.ValidString(n.body) {
+		jn.Content = n.body
+	} else {
+		jn.Content = base64.StdEncoding.EncodeToString([]byte(n.body))
+		jn.Encoding = "base64"
+	}
+
+	for name, value := range n.xattrs {
+		if jn.Xattrs == nil {
+			jn.Xattrs = make(map[string]string, len(n.xattrs))
+		}
+
+		if utf8.ValidString(value) {
+			jn.Xattrs[name] = value
+			continue
+		}
+
+		if jn.XattrEncodings == nil {
+			jn.XattrEncodings = make(map[string]string)
+		}
+		jn.Xattrs[name] = base64.StdEncoding.EncodeToString([]byte(value))
+		jn.XattrEncodings[name] = "base64"
+	}
+
+	return jn
+}
+
+// WriteJSON encodes the nodes into the JSON tree description
+// format, as read by ParseJSON. Content and extended
+// attributes' values, which are not valid UTF-8, are encoded
+// as base64.
+func WriteJSON(f Fatalfable, w io.Writer, nodes []*Node) {
+
+	jns := make([]*jsonNode, len(nodes))
+	for i, n := range nodes {
+		jns[i] = toJSON(n)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(jns)
+	if err != nil {
+		f.Fatalf("Encoding the JSON tree description: %q", err)
+	}
+}
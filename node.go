@@ -9,34 +9,78 @@ import (
 )
 
 // Node holds basic attributes of a filesystem item.
-// Its name is relative to CWD.
+// Its name is relative to CWD. A non-empty link makes
+// the node a symbolic link pointing to the link value.
+// A non-empty hard makes the node a hard link to the
+// file at the hard path. A non-empty from is a path to
+// a file to copy the node content from. The xattrs are
+// user extended attributes of the filesystem item. The
+// atime is the access time, which is the same as the
+// modification time if zero.
 type Node struct {
-	perm os.FileMode
-	time time.Time
-	name string
-	body string
+	perm   os.FileMode
+	time   time.Time
+	atime  time.Time
+	name   string
+	body   string
+	link   string
+	hard   string
+	from   string
+	xattrs map[string]string
 }
 
-// SaveAttributes sets the named file's permissions and
-// timestamps to the ones from the node.
+// SaveAttributes sets the named file's extended attributes,
+// permissions, and timestamps to the ones from the node.
+// Symbolic links are left as is, as their own attributes can
+// not be portably changed. Hard links are left as is too, as
+// they share attributes with the linked file. Setting extended
+// attributes fails where they are not supported.
 func (n *Node) SaveAttributes(f Fatalfable) {
 
+	if n.link != "" || n.hard != "" {
+		return
+	}
+
+	for _, name := range sortedXattrs(n.xattrs) {
+		err := setXattr(n.name, name, n.xattrs[name])
+		if err != nil {
+			f.Fatalf("Setting %q extended attribute %q: %s", n.name, name, err)
+		}
+	}
+
 	err := os.Chmod(n.name, n.perm)
 	if err != nil {
-		f.Fatalf("Setting %q permissions to %o: %q", n.name, n.perm, err)
+		f.Fatalf("Setting %q permissions to %s: %q", n.name, octal(n.perm), err)
 	}
 
-	err = os.Chtimes(n.name, n.time, n.time)
+	err = os.Chtimes(n.name, n.accessTime(), n.time)
 	if err != nil {
 		f.Fatalf("Setting %q timestamps to %s: %q", n.name, n.time, err)
 	}
 }
 
+// accessTime returns the node's access time, defaulting to
+// its modification time
+func (n *Node) accessTime() time.Time {
+	if n.atime.IsZero() {
+		return n.time
+	}
+	return n.atime
+}
+
+// attrMode keeps the permissions, and the setuid, setgid,
+// and sticky bits of the file mode, which are set by
+// SaveAttributes
+func attrMode(mode os.FileMode) os.FileMode {
+	return mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
 // Rfc3339 converts a string to a time struct while assuming
-// the string is formatted according to RFC3339. It calls
+// the string is formatted according to RFC3339, optionally
+// with fractional seconds up to nanoseconds. It calls
 // f.Fatalf if the conversion fails.
 func Rfc3339(f Fatalfable, ts string) time.Time {
-	tm, err := time.Parse(time.RFC3339, ts)
+	tm, err := time.Parse(time.RFC3339Nano, ts)
 	if err != nil {
 		f.Fatalf("Failed to convert %q to a time: %q", ts, err)
 	}
@@ -0,0 +1,70 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError describes a problem found while parsing a line
+// of a tree description
+type ParseError struct {
+	// Line is the 1-based number of the problematic line
+	Line int
+
+	// Field is the number of the problematic field, as in the
+	// ParseReader format description, regardless of omitted
+	// fields. It is zero for problems with the whole line.
+	Field int
+
+	// Column is the 1-based byte offset of the problematic
+	// field in the line, or of the line content, if Field is
+	// zero
+	Column int
+
+	// Text is the problematic line
+	Text string
+
+	// Err is the reason of the problem
+	Err error
+}
+
+func (pe *ParseError) Error() string {
+
+	loc := fmt.Sprintf("line %d", pe.Line)
+	if pe.Column > 0 {
+		loc = loc + fmt.Sprintf(", column %d", pe.Column)
+	}
+	if pe.Field > 0 {
+		loc = loc + fmt.Sprintf(", field %d", pe.Field)
+	}
+
+	if pe.Text == "" {
+		return fmt.Sprintf("%s: %s", loc, pe.Err)
+	}
+	return fmt.Sprintf("%s: %s in %q", loc, pe.Err, pe.Text)
+}
+
+// ParseErrors is a list of all problems found while parsing
+// a tree description, in the order of lines
+type ParseErrors []*ParseError
+
+func (pes ParseErrors) Error() string {
+	msgs := make([]string, len(pes))
+	for i, pe := range pes {
+		msgs[i] = pe.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// fieldErr is a problem located at a field of a line
+type fieldErr struct {
+	field, column int
+	err           error
+}
+
+func (fe *fieldErr) Error() string {
+	return fe.err.Error()
+}
@@ -0,0 +1,172 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PathMatch is the signature of functions which select
+// filesystem objects to be excluded from TreeDiffExcept,
+// TreeCopyExcept, and TempCloneDirExcept, or removed by
+// FileDelMatch. The rel parameter is the object's path
+// relative to the processed tree's root, with forward
+// slashes as separators regardless of the platform.
+//
+// When a directory is matched, none of its content is
+// visited, the same way Git does not look inside ignored
+// directories.
+type PathMatch func(rel string, fi os.FileInfo) bool
+
+type pattern struct {
+	negate   bool
+	dirOnly  bool
+	segments []string
+}
+
+// Patterns returns a PathMatch function, which matches paths
+// with gitignore-style patterns:
+//
+// - blank lines and lines starting with "#" are ignored;
+//
+// - a leading "!" negates the pattern, re-including objects
+// matched by an earlier pattern;
+//
+// - a trailing "/" makes the pattern match only directories;
+//
+// - a pattern with no other "/" matches names at any depth,
+// otherwise it is matched against the full relative path;
+//
+// - "*", "?", and "[...]" match within a path element as in
+// path.Match, and "**" matches any number of path elements.
+//
+// The last matching pattern decides if a path is matched.
+// Malformed patterns are reported via the Fatalfable.
+func Patterns(f Fatalfable, patterns ...string) PathMatch {
+
+	compiled := make([]pattern, 0, len(patterns))
+
+	for _, orig := range patterns {
+		line := strings.TrimRight(orig, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p pattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasPrefix(line, "\\") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		p.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+
+		for _, seg := range p.segments {
+			if _, err := path.Match(seg, ""); err != nil {
+				f.Fatalf("Malformed pattern %q: %s", orig, err)
+			}
+		}
+
+		compiled = append(compiled, p)
+	}
+
+	return func(rel string, fi os.FileInfo) bool {
+		matched := false
+		name := strings.Split(rel, "/")
+		for _, p := range compiled {
+			if p.dirOnly && !fi.IsDir() {
+				continue
+			}
+			if matchSegments(p.segments, name) {
+				matched = !p.negate
+			}
+		}
+		return matched
+	}
+}
+
+// PatternsFile reads gitignore-style patterns from the file,
+// one per line, and returns a PathMatch function as Patterns
+// does for them
+func PatternsFile(f Fatalfable, fn string) PathMatch {
+
+	file, err := os.Open(fn)
+	if err != nil {
+		f.Fatalf("Opening the patterns file %q: %s", fn, err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		f.Fatalf("Reading the patterns file %q: %s", fn, err)
+	}
+
+	return Patterns(f, lines...)
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchRel applies the match to the path of a walked object,
+// if the match is provided. It returns filepath.SkipDir for
+// matched directories, so that their content is not walked.
+func matchRel(match PathMatch, root, fn string, fi os.FileInfo) (bool, error) {
+
+	if match == nil {
+		return false, nil
+	}
+
+	rel, err := filepath.Rel(root, fn)
+	if err != nil {
+		return false, err
+	}
+
+	if !match(filepath.ToSlash(rel), fi) {
+		return false, nil
+	}
+
+	if fi.IsDir() {
+		return true, filepath.SkipDir
+	}
+	return true, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+
+	nodes := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "build/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "build/out.o", body: "o"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/.DS_Store", body: "x"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/main.go", body: "m"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/keep.lock", body: "k"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/go.lock", body: "g"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/cache/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/cache/data", body: "d"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "cache", body: "c"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	match := Patterns(t,
+		"# noise",
+		"",
+		".DS_Store",
+		"*.lock",
+		"!keep.lock",
+		"/build",
+		"src/**/data",
+		"cache/",
+	)
+
+	expected := map[string]bool{
+		"build":          true,
+		"build/out.o":    false,
+		"src":            false,
+		"src/.DS_Store":  true,
+		"src/main.go":    false,
+		"src/keep.lock":  false,
+		"src/go.lock":    true,
+		"src/cache":      true,
+		"src/cache/data": true,
+		"cache":          false,
+	}
+
+	for rel, exp := range expected {
+		fi, err := os.Lstat(rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match(rel, fi) != exp {
+			t.Errorf("Expected %q to be matched: %v", rel, exp)
+		}
+	}
+}
+
+func TestPatternsMalformed(t *testing.T) {
+
+	fr := &fatalRecorder{}
+	Patterns(fr, "ok", "bad[")
+
+	if len(fr.msgs) != 1 {
+		t.Errorf("Expected a malformed pattern failure, got %v", fr.msgs)
+	}
+}
+
+func TestExcept(t *testing.T) {
+
+	nodes := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/f", body: "f"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/f.tmp", body: "t"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/.cache/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/.cache/c", body: "c"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/f", body: "f"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/f.tmp", body: "other"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	match := Patterns(t, "*.tmp", ".cache/")
+
+	if diffs := TreeDiff(t, "a", "b", SizeRank); diffs == nil {
+		t.Error("Differing directories passed as equivalent without exclusions")
+	}
+
+	if diffs := TreeDiffExcept(t, "a", "b", match, SizeRank); diffs != nil {
+		t.Errorf("Excluded objects are compared: %v", diffs)
+	}
+
+	if err := os.Mkdir("c", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	TreeCopyExcept(t, "a", "c", match)
+
+	for _, fn := range []string{"c/f.tmp", "c/.cache"} {
+		if _, err := os.Lstat(fn); !os.IsNotExist(err) {
+			t.Errorf("Excluded %q is copied: %v", fn, err)
+		}
+	}
+
+	if diffs := TreeDiff(t, "b", "c", SizeRank); len(diffs) != 1 {
+		t.Errorf("Expected only the right f.tmp to differ, got %v", diffs)
+	}
+
+	FileDelMatch(t, "a", match)
+
+	if diffs := TreeDiff(t, "a", "c", SizeRank, ContentRank(t)); diffs != nil {
+		t.Errorf("Matched objects are not deleted: %v", diffs)
+	}
+}
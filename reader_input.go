@@ -2,11 +2,21 @@ package fst // import "go.didenko.com/fst"
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type emptyErr struct {
@@ -14,55 +24,840 @@ type emptyErr struct {
 }
 
 var (
-	re    = regexp.MustCompile(`^\s*([-0-9T:Z]+)\t+(0[0-7]{0,4})\t+([^\t]+)(\t+([^\t]+))?\s*$`)
-	empty = regexp.MustCompile(`^\s*$`)
+	tabs     = regexp.MustCompile(`\t+`)
+	timeLike = regexp.MustCompile(`^(\d{4}-\d\d-\d\dT|now([-+,]|$)|[-+]\d)`)
+	permLike = regexp.MustCompile(`^(0[0-7]{0,4}|[1-7][0-7]{3})$`)
+	numLike  = regexp.MustCompile(`^\d+$`)
+	header   = regexp.MustCompile(`^\[([^\]]+)\]$`)
+	span     = regexp.MustCompile(`(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h|d|w)`)
 )
 
-func parse(line string) (time.Time, os.FileMode, string, string, error) {
+const (
+	linkMark    = "->"
+	hardMark    = "=>"
+	heredocMark = "<<"
+	fileMark    = "file:"
+	nowMark     = "now"
 
-	if empty.MatchString(line) {
-		return time.Time{}, 0, "", "", &emptyErr{}
+	commentMark   = "#"
+	directiveMark = "%"
+	includeMark   = "%include"
+	xattrMark     = "%xattr"
+
+	// maxLineSize is the longest line accepted in a tree
+	// description, so that snapshots of files with long lines
+	// can be read back
+	maxLineSize = 1 << 30
+)
+
+// Parser holds settings for parsing tree descriptions. Its
+// zero value is ready to use and is what the ParseReader
+// and ParseFile functions use.
+type Parser struct {
+	// Dir is the directory against which relative "file:"
+	// content references and included files are resolved.
+	// The working directory is used if it is empty.
+	Dir string
+
+	// Now is the reference time for relative timestamps. The
+	// current time at the start of parsing is used if it is
+	// the zero time.
+	Now time.Time
+
+	// Section is the name of the section to parse. Records
+	// before a first section header are parsed if it is empty.
+	Section string
+
+	includes []string
+}
+
+func unquote(s string) (string, error) {
+
+	if len(s) == 0 || (s[0] != '`' && s[0] != '"') {
+		return s, nil
 	}
 
-	parts := re.FindStringSubmatch(line)
+	if len(s) == 1 || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("the quote in %s is unbalanced", s)
+	}
 
-	mt, err := time.Parse(time.RFC3339, parts[1])
+	uq, err := strconv.Unquote(s)
 	if err != nil {
-		return time.Time{}, 0, "", "", err
+		return "", fmt.Errorf("the quoted string %s is malformed", s)
 	}
-	mt = mt.Round(0)
 
-	perm64, err := strconv.ParseUint(parts[2], 8, 32)
+	return uq, nil
+}
+
+// defaults hold field values, which are set by directives
+// for records omitting the fields
+type defaults struct {
+	time, atime                  time.Time
+	perm, dirPerm                os.FileMode
+	hasTime, hasPerm, hasDirPerm bool
+}
+
+func (p Parser) parse(line string, next func() (string, bool), dflt *defaults) (*Node, error) {
+
+	fields, cols := split(line)
+
+	if len(fields) == 0 || strings.HasPrefix(fields[0], commentMark) {
+		return nil, &emptyErr{}
+	}
+
+	if strings.HasPrefix(fields[0], directiveMark) {
+		err := p.directive(strings.TrimSpace(line)[len(directiveMark):], dflt)
+		if err != nil {
+			return nil, &fieldErr{0, cols[0], err}
+		}
+		return nil, &emptyErr{}
+	}
+
+	var err error
+	i := 0
+
+	mt, at, hasAtime := dflt.time, dflt.atime, false
+	if timeLike.MatchString(fields[i]) {
+		mt, at, err = p.times(fields[i])
+		if err != nil {
+			return nil, &fieldErr{1, cols[i], err}
+		}
+		hasAtime = !at.IsZero()
+		i++
+	} else if !dflt.hasTime {
+		return nil, &fieldErr{1, cols[i], errors.New("the time field is omitted without a %time directive")}
+	}
+
+	perm, hasPerm := os.FileMode(0), false
+	if i < len(fields) && (permLike.MatchString(fields[i]) || i > 0 && numLike.MatchString(fields[i])) {
+		perm, err = permission(fields[i])
+		if err != nil {
+			return nil, &fieldErr{2, cols[i], err}
+		}
+		i++
+		hasPerm = true
+	}
+
+	if i == len(fields) {
+		return nil, &fieldErr{3, len(line) + 1, errors.New("the name field is missing")}
+	}
+
+	if len(fields)-i > 2 {
+		return nil, &fieldErr{5, cols[i+2], fmt.Errorf("there are %d fields after the name field, while 1 is expected at most", len(fields)-i-1)}
+	}
+
+	path, err := unquote(fields[i])
 	if err != nil {
-		return time.Time{}, 0, "", "", err
+		return nil, &fieldErr{3, cols[i], err}
+	}
+
+	if path == "" {
+		return nil, &fieldErr{3, cols[i], errors.New("the name is empty")}
+	}
+
+	if !hasPerm {
+		switch {
+		case path[len(path)-1] == '/' && dflt.hasDirPerm:
+			perm = dflt.dirPerm
+		case dflt.hasPerm:
+			perm = dflt.perm
+		default:
+			return nil, &fieldErr{2, cols[i], errors.New("the permissions field is omitted without a %perm directive")}
+		}
 	}
 
-	perm := os.FileMode(perm64)
+	node := &Node{perm: perm, time: mt, atime: at, name: path}
+
+	if i+1 == len(fields) {
+		return node, nil
+	}
+
+	field := fields[i+1]
+
+	if hasAtime && (strings.HasPrefix(field, linkMark) || strings.HasPrefix(field, hardMark)) {
+		return nil, &fieldErr{1, cols[0], errors.New("an access time can not be set on links")}
+	}
 
-	var path string
-	if parts[3][0] == '`' || parts[3][0] == '"' {
-		path, err = strconv.Unquote(parts[3])
+	switch {
+	case strings.HasPrefix(field, linkMark):
+		node.link, err = linkTarget(path, field[len(linkMark):], "symbolic")
+	case strings.HasPrefix(field, hardMark):
+		node.hard, err = linkTarget(path, field[len(hardMark):], "hard")
+	case strings.HasPrefix(field, fileMark):
+		node.from, err = p.source(field[len(fileMark):])
+	default:
+		node.body, err = content(field, next)
+	}
+
+	if err != nil {
+		return nil, &fieldErr{4, cols[i+1], err}
+	}
+
+	return node, nil
+}
+
+// split breaks a line into tab-separated fields after trimming
+// white space on both ends, and finds the fields' 1-based
+// columns in the original line
+func split(line string) ([]string, []int) {
+
+	indent := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+	line = strings.TrimSpace(line)
+
+	if line == "" {
+		return nil, nil
+	}
+
+	fields := make([]string, 0, 4)
+	cols := make([]int, 0, 4)
+
+	pos := 0
+	for _, sep := range tabs.FindAllStringIndex(line, -1) {
+		fields = append(fields, line[pos:sep[0]])
+		cols = append(cols, indent+pos+1)
+		pos = sep[1]
+	}
+
+	return append(fields, line[pos:]), append(cols, indent+pos+1)
+}
+
+// directive sets a default field value for the following
+// records from a directive line with the leading mark removed
+func (p Parser) directive(line string, dflt *defaults) error {
+
+	args := strings.Fields(line)
+	if len(args) != 2 {
+		return fmt.Errorf("the directive %q expects a single value", directiveMark+line)
+	}
+
+	var err error
+
+	switch args[0] {
+	case "time":
+		dflt.time, dflt.atime, err = p.times(args[1])
+		dflt.hasTime = true
+	case "perm":
+		dflt.perm, err = permission(args[1])
+		dflt.hasPerm = true
+	case "dirperm":
+		dflt.dirPerm, err = permission(args[1])
+		dflt.hasDirPerm = true
+	default:
+		return fmt.Errorf("the directive %q is unknown", directiveMark+args[0])
+	}
+
+	return err
+}
+
+func permission(field string) (os.FileMode, error) {
+
+	if !permLike.MatchString(field) {
+		return 0, fmt.Errorf("permissions %q are not an octal number with a leading zero or four digits", field)
+	}
+
+	perm64, err := strconv.ParseUint(field, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	perm := os.FileMode(perm64) & os.ModePerm
+	for bit, mode := range specialBits {
+		if perm64&bit != 0 {
+			perm |= mode
+		}
+	}
+
+	return perm, nil
+}
+
+// specialBits maps Unix setuid, setgid, and sticky bits
+// to their os.FileMode counterparts
+var specialBits = map[uint64]os.FileMode{
+	04000: os.ModeSetuid,
+	02000: os.ModeSetgid,
+	01000: os.ModeSticky,
+}
+
+// octal formats permissions as accepted by the permission
+// function
+func octal(perm os.FileMode) string {
+	bits := uint64(perm & os.ModePerm)
+	for bit, mode := range specialBits {
+		if perm&mode != 0 {
+			bits |= bit
+		}
+	}
+	return fmt.Sprintf("%#o", bits)
+}
+
+// times converts a time field, which is a modification time
+// optionally followed by a comma and an access time
+func (p Parser) times(field string) (mt, at time.Time, err error) {
+
+	parts := strings.SplitN(field, ",", 2)
+
+	mt, err = p.timestamp(parts[0])
+	if err != nil || len(parts) == 1 {
+		return mt, time.Time{}, err
+	}
+
+	if parts[1] == "" {
+		return mt, time.Time{}, errors.New("the access time after the comma is empty")
+	}
+
+	at, err = p.timestamp(parts[1])
+	return mt, at, err
+}
+
+// timestamp converts a time field, which is either in the
+// RFC3339 format with optional fractional seconds, or is
+// relative to the parser's Now time
+func (p Parser) timestamp(field string) (time.Time, error) {
+
+	rel := field
+	if strings.HasPrefix(rel, nowMark) {
+		rel = rel[len(nowMark):]
+		if rel == "" {
+			return p.Now.Round(0), nil
+		}
+	}
+
+	if rel[0] != '-' && rel[0] != '+' {
+		mt, err := time.Parse(time.RFC3339Nano, field)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return mt.Round(0), nil
+	}
+
+	d, err := duration(rel[1:])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing relative time %q: %s", field, err)
+	}
+
+	if rel[0] == '-' {
+		d = -d
+	}
+
+	return p.Now.Add(d).Round(0), nil
+}
+
+// duration parses a sequence of decimal numbers, each with
+// a unit suffix, as time.ParseDuration does, while also
+// accepting the "d" suffix for days and "w" for weeks
+func duration(s string) (time.Duration, error) {
+
+	var d time.Duration
+	pos := 0
+
+	for _, m := range span.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] != pos {
+			break
+		}
+		pos = m[1]
+
+		num, unit := s[m[2]:m[3]], s[m[4]:m[5]]
+
+		mult := time.Duration(0)
+		switch unit {
+		case "d":
+			mult = 24 * time.Hour
+		case "w":
+			mult = 7 * 24 * time.Hour
+		}
+
+		if mult == 0 {
+			part, err := time.ParseDuration(num + unit)
+			if err != nil {
+				return 0, err
+			}
+			d += part
+			continue
+		}
+
+		n, err := strconv.ParseFloat(num, 64)
 		if err != nil {
-			return time.Time{}, 0, "", "", err
+			return 0, err
 		}
-	} else {
-		path = parts[3]
+		d += time.Duration(n * float64(mult))
+	}
+
+	if pos == 0 || pos != len(s) {
+		return 0, fmt.Errorf("invalid duration %q", s)
 	}
 
-	var content string
-	if len(parts[5]) > 0 {
+	return d, nil
+}
+
+// encodings hold decoders for content fields prefixed
+// with an encoding name and a colon
+var encodings = map[string]func(string) ([]byte, error){
+	"base64": base64.StdEncoding.DecodeString,
+	"hex":    hex.DecodeString,
+	"gzip+base64": func(s string) ([]byte, error) {
+		zipped, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(zipped))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		return ioutil.ReadAll(zr)
+	},
+}
+
+func content(field string, next func() (string, bool)) (string, error) {
+
+	if i := strings.IndexByte(field, ':'); i > 0 {
+		if _, ok := encodings[field[:i]]; ok {
 
-		if parts[5][0] == '`' || parts[5][0] == '"' {
-			content, err = strconv.Unquote(parts[5])
+			encoded, err := literal(field[i+1:], next)
 			if err != nil {
-				return time.Time{}, 0, "", "", err
+				return "", err
+			}
+
+			return decode(field[:i], encoded)
+		}
+	}
+
+	return literal(field, next)
+}
+
+// decode decodes the data with one of the named encodings
+// after removing white space from the data
+func decode(encoding, data string) (string, error) {
+
+	decoder, ok := encodings[encoding]
+	if !ok {
+		return "", fmt.Errorf("the content encoding %q is unknown", encoding)
+	}
+
+	decoded, err := decoder(strings.Join(strings.Fields(data), ""))
+	if err != nil {
+		return "", fmt.Errorf("decoding %s content: %s", encoding, err)
+	}
+
+	return string(decoded), nil
+}
+
+func literal(field string, next func() (string, bool)) (string, error) {
+	if strings.HasPrefix(field, heredocMark) {
+		return heredoc(field[len(heredocMark):], next)
+	}
+	return unquote(field)
+}
+
+// heredoc collects lines provided by the next function
+// until a line with only the terminator in it. If the
+// terminator is prefixed with a dash, then indentation of
+// the terminator line is removed from all collected lines.
+func heredoc(term string, next func() (string, bool)) (string, error) {
+
+	strip := strings.HasPrefix(term, "-")
+	if strip {
+		term = term[1:]
+	}
+
+	if term == "" || strings.ContainsAny(term, " \t") {
+		return "", fmt.Errorf("a heredoc terminator %q is invalid", term)
+	}
+
+	lines := make([]string, 0)
+
+	for {
+		line, ok := next()
+		if !ok {
+			return "", fmt.Errorf("a heredoc is not closed by the %q terminator", term)
+		}
+
+		if strings.TrimSpace(line) != term {
+			lines = append(lines, line)
+			continue
+		}
+
+		if strip {
+			indent := line[:strings.Index(line, term)]
+			for i, l := range lines {
+				if strings.HasPrefix(l, indent) {
+					lines[i] = l[len(indent):]
+					continue
+				}
+				if strings.TrimSpace(l) != "" {
+					return "", fmt.Errorf("a heredoc line %q is indented less than its %q terminator", l, term)
+				}
+				lines[i] = ""
 			}
-		} else {
-			content = parts[5]
 		}
+
+		if len(lines) == 0 {
+			return "", nil
+		}
+
+		return strings.Join(lines, "\n") + "\n", nil
+	}
+}
+
+// source unquotes a content file reference field and
+// resolves it relative to the parser's directory
+func (p Parser) source(field string) (string, error) {
+
+	name, err := unquote(field)
+	if err != nil {
+		return "", err
 	}
 
-	return mt, perm, path, content, nil
+	return p.resolve(name)
+}
+
+// resolve resolves a content file reference relative to the
+// parser's directory and checks that it is a regular file
+func (p Parser) resolve(name string) (string, error) {
+
+	var err error
+
+	if name == "" {
+		return "", errors.New("a content file name is empty")
+	}
+
+	if !filepath.IsAbs(name) {
+		name, err = filepath.Abs(filepath.Join(p.Dir, name))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return "", err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("a content file %q is not a regular file", name)
+	}
+
+	return name, nil
+}
+
+func linkTarget(path, field, kind string) (string, error) {
+
+	if path[len(path)-1] == '/' {
+		return "", fmt.Errorf("a directory can not be a %s link", kind)
+	}
+
+	target, err := unquote(strings.TrimSpace(field))
+	if err != nil {
+		return "", err
+	}
+
+	if target == "" {
+		return "", fmt.Errorf("a %s link target is empty", kind)
+	}
+
+	if kind == "hard" && strings.HasSuffix(target, "/") {
+		return "", errors.New("a hard link target can not be a directory")
+	}
+
+	return target, nil
+}
+
+// ParseReader parses a suplied Reader for the tree
+// information in the same way as the ParseReader function,
+// but with the parser's settings.
+func (p Parser) ParseReader(f Fatalfable, config io.Reader) []*Node {
+
+	entries, err := p.Parse(config)
+	if err != nil {
+		f.Fatalf("While parsing the tree description:\n%s", err)
+	}
+
+	return entries
+}
+
+// Parse parses a suplied Reader for the tree information in
+// the same way as the ParseReader method, but does not stop
+// at a first problem. Instead, it collects problems with all
+// lines and returns them as ParseErrors, along with the nodes
+// from lines without problems. Problems reading the Reader
+// are reported as a ParseError without a line text.
+func (p Parser) Parse(config io.Reader) ([]*Node, error) {
+	return p.parseWith(config, &defaults{})
+}
+
+// parseWith parses the input starting with the provided
+// defaults, and leaves the defaults as set by the last
+// section's directives
+func (p Parser) parseWith(config io.Reader, dflt *defaults) ([]*Node, error) {
+
+	entries := make([]*Node, 0, 10)
+	errs := make(ParseErrors, 0)
+
+	if p.Now.IsZero() {
+		p.Now = time.Now()
+	}
+
+	num := 0
+	scanner := bufio.NewScanner(config)
+	scanner.Buffer(nil, maxLineSize)
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		num++
+		return scanner.Text(), true
+	}
+
+	section, found := "", p.Section == ""
+
+	// last is the record, which %xattr directives apply to
+	var last *Node
+
+	for line, ok := next(); ok; line, ok = next() {
+
+		at := num
+		trimmed := strings.TrimSpace(line)
+
+		if m := header.FindStringSubmatch(trimmed); m != nil {
+			section = strings.TrimSpace(m[1])
+			found = found || section == p.Section
+			*dflt = defaults{}
+			last = nil
+			continue
+		}
+
+		if fs := strings.Fields(trimmed); len(fs) > 0 && fs[0] == includeMark {
+			if section != p.Section {
+				continue
+			}
+
+			last = nil
+			nodes, err := p.include(strings.TrimSpace(trimmed[len(includeMark):]))
+			if err != nil {
+				_, cols := split(line)
+				errs = append(errs, &ParseError{at, 0, cols[0], line, err})
+				continue
+			}
+
+			entries = append(entries, nodes...)
+			continue
+		}
+
+		if fs := strings.Fields(trimmed); len(fs) > 0 && fs[0] == xattrMark {
+			if section != p.Section {
+				continue
+			}
+
+			err := xattr(last, strings.TrimSpace(trimmed[len(xattrMark):]))
+			if err != nil {
+				_, cols := split(line)
+				errs = append(errs, &ParseError{at, 0, cols[0], line, err})
+			}
+			continue
+		}
+
+		// Records of other sections are still parsed to skip
+		// their heredocs, but their problems are not reported
+		node, err := p.parse(line, next, dflt)
+		if section != p.Section {
+			continue
+		}
+
+		switch err := err.(type) {
+		case nil:
+			entries = append(entries, node)
+			last = node
+		case *emptyErr:
+		case *fieldErr:
+			errs = append(errs, &ParseError{at, err.field, err.column, line, err.err})
+		default:
+			errs = append(errs, &ParseError{Line: at, Text: line, Err: err})
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		errs = append(errs, &ParseError{Line: num + 1, Err: err})
+	}
+
+	if !found {
+		return nil, fmt.Errorf("the section %q is not found", p.Section)
+	}
+
+	if len(errs) > 0 {
+		return entries, errs
+	}
+
+	return entries, nil
+}
+
+// xattr adds an extended attribute to the node from the xattr
+// directive arguments, which are the attribute name and
+// an optionally quoted value
+func xattr(n *Node, args string) error {
+
+	if n == nil {
+		return errors.New("the extended attribute does not follow a record")
+	}
+
+	if n.link != "" || n.hard != "" {
+		return errors.New("extended attributes can not be set on links")
+	}
+
+	name, value := args, ""
+	if i := strings.IndexAny(args, " \t"); i >= 0 {
+		name, value = args[:i], strings.TrimSpace(args[i:])
+	}
+
+	if err := xattrName(name); err != nil {
+		return err
+	}
+
+	value, err := unquote(value)
+	if err != nil {
+		return err
+	}
+
+	if n.xattrs == nil {
+		n.xattrs = make(map[string]string)
+	}
+	n.xattrs[name] = value
+
+	return nil
+}
+
+// closingQuote finds the index of the quote closing the one
+// the string starts with, skipping backslash escapes inside
+// double quotes. It returns -1 if the quote is not closed.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == s[0]:
+			return i
+		case s[i] == '\\' && s[0] == '"':
+			i++
+		}
+	}
+	return -1
+}
+
+// include parses the section of a tree description file
+// from the include directive arguments, which are the
+// file path and an optional section name
+func (p Parser) include(args string) ([]*Node, error) {
+
+	path, section := args, ""
+
+	if len(args) > 0 && (args[0] == '"' || args[0] == '`') {
+		end := closingQuote(args)
+		if end < 0 {
+			return nil, fmt.Errorf("the quote in the include path %s is unbalanced", args)
+		}
+		path, section = args[:end+1], args[end+1:]
+	} else if i := strings.IndexAny(args, " \t"); i >= 0 {
+		path, section = args[:i], args[i:]
+	}
+
+	path, err := unquote(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		return nil, errors.New("the include path is empty")
+	}
+
+	section = strings.TrimSpace(section)
+	if strings.ContainsAny(section, " \t") {
+		return nil, fmt.Errorf("the include section %q has white space in it", section)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.Dir, path)
+	}
+
+	sub := p
+	sub.Section = section
+
+	err = sub.enter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer config.Close()
+
+	return sub.Parse(config)
+}
+
+// enter records that the parser parses a section of the
+// file, and resets its directory to the file's directory.
+// It is an error to enter the same section of the same file
+// while it is being parsed.
+func (p *Parser) enter(path string) error {
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	key := abs + "[" + p.Section + "]"
+
+	for _, k := range p.includes {
+		if k == key {
+			return fmt.Errorf("the section %q of %q includes itself", p.Section, path)
+		}
+	}
+
+	p.includes = append(p.includes[:len(p.includes):len(p.includes)], key)
+	p.Dir = filepath.Dir(abs)
+
+	return nil
+}
+
+// ParseFile opens the named file and parses it as the
+// ParseReader method does. Unless the parser's Dir is set,
+// relative "file:" content references and included files
+// are resolved against the parsed file's directory.
+func (p Parser) ParseFile(f Fatalfable, path string) []*Node {
+
+	config, err := os.Open(path)
+	if err != nil {
+		f.Fatalf("Opening the tree description file %q: %q", path, err)
+	}
+	defer config.Close()
+
+	dir := p.Dir
+
+	err = p.enter(path)
+	if err != nil {
+		f.Fatalf("Parsing the tree description file %q: %q", path, err)
+	}
+
+	if dir != "" {
+		p.Dir = dir
+	}
+
+	return p.ParseReader(f, config)
+}
+
+// ParseFile parses the named tree description file with
+// the default Parser settings. See ParseReader for the
+// file format.
+func ParseFile(f Fatalfable, path string) []*Node {
+	return Parser{}.ParseFile(f, path)
+}
+
+// LoadTree parses the named section of the tree description
+// file with otherwise default Parser settings. It allows for
+// keeping trees for many tests in a single file.
+func LoadTree(f Fatalfable, path, section string) []*Node {
+	return Parser{Section: section}.ParseFile(f, path)
 }
 
 // ParseReader parses a suplied Reader for the tree
@@ -72,15 +867,70 @@ func parse(line string) (time.Time, os.FileMode, string, string, error) {
 //
 // The input has line records with three or four fields
 // separated by one or more tabs. White space is trimmed on
-// both ends of lines. Empty lines are skipped. The general
-// line format is:
+// both ends of lines. Empty lines and comment lines, which
+// start with "#", are skipped. The general line format is:
 //
 // <1. time>	<2. permissions>	<3. name> <4. optional content>
 //
+// Lines starting with "%" are directives, which set default
+// values of Fields 1 and 2 for the following records:
+//
+// %time <time>      - time, in any of the Field 1 formats
+//
+// %perm <octal>     - permissions for files and directories
+//
+// %dirperm <octal>  - permissions for directories, overrides
+// the %perm directive for them
+//
+// %include <path> [<section>] - records from the section of
+// another tree description file, the section before a first
+// section header if no section is provided. A relative path is
+// resolved against the Parser's Dir setting, same as content
+// file references below. Paths with white space in them
+// should be quoted.
+//
+// %xattr <name> <value> - a user extended attribute of the
+// preceding record's file or directory. The name must be in
+// the "user." namespace. The value may be quoted the same way
+// as paths in Field 3, and may be omitted for an empty value.
+// Extended attributes can not be set on links.
+//
+// A line with a name in square brackets, like "[skeleton]",
+// starts a named section of the input. Records before a first
+// section header belong to an unnamed section. Sections are
+// parsed one at a time, as selected by the Parser's Section
+// setting. The ParseReader and ParseFile functions use the
+// unnamed section, while the LoadTree function selects
+// a named one. Problems in records of other sections are not
+// reported. Directives' defaults do not carry over to the
+// following sections.
+//
+// A record may omit Field 1, Field 2, or both, if the omitted
+// fields have defaults set by the directives. Fields are
+// recognized by their looks, so a name looking like a time or
+// a number should be quoted. Same applies to names starting
+// with "#", "%", or "[" in records omitting Fields 1 and 2.
+//
 // Field 1: Time in RFC3339 format, as shown at
-// https://golang.org/pkg/time/#RFC3339
+// https://golang.org/pkg/time/#RFC3339, with optional
+// fractional seconds up to nanoseconds, as in RFC3339Nano,
+// like "2001-01-01T01:01:01.123456789Z", or a time relative
+// to the reference time. The reference time is the Parser's
+// Now setting, which is the current time for the ParseReader
+// function. Relative times are "now", or a signed duration
+// optionally prefixed with "now", like "-7d", "now-90m", or
+// "+1h30m". Durations are in the time.ParseDuration format
+// with additional "d" units for days and "w" for weeks.
+// The time is the modification time. It may be followed by
+// a comma and a distinct access time in any of the same
+// formats, like "2001-01-01T01:01:01Z,now". Otherwise the
+// access time is the same as the modification time.
 //
-// Field 2: Octal (required) representation of FileMode, as at
+// Field 2: Octal representation of Unix permissions, with
+// a leading zero required for less than four digits, like
+// "0640", "0755", or "1777". The setuid (04000), setgid
+// (02000), and sticky (01000) bits are translated into the
+// respective os.FileMode bits, as at
 // https://golang.org/pkg/os/#FileMode
 //
 // Field 3: is the file or directory path to be created. If the
@@ -96,28 +946,50 @@ func parse(line string) (time.Time, os.FileMode, string, string, error) {
 // Field 4: is optional content to be written into the file. It
 // follows the same quotation rules as paths in Field 3.
 // Directory entries ignore Field 4 if present.
+//
+// Binary content is provided in Field 4 with an encoding
+// prefix: "base64:", "hex:", or "gzip+base64:", followed by
+// the encoded data. The data may be quoted as paths in
+// Field 3, and white space in it is ignored. The decoded
+// bytes are written into the file exactly. A content, which
+// literally starts with one of the prefixes, should be quoted.
+//
+// Multi-line content is provided in Field 4 as a heredoc: the
+// "<<" marker immediately followed by a terminator word, as in
+// "<<EOF". The content is in the lines following the record,
+// up to a line with only the terminator word and optional
+// white space around it. The content lines are taken as is,
+// each of them ends with a newline, including the last one.
+// An empty heredoc makes an empty content. If the terminator
+// word is prefixed with a dash, as in "<<-EOF", then the
+// terminator line's indentation is removed from the start of
+// every content line, so that the content can be indented
+// along with the records. A content line, indented less than
+// the terminator, is an error unless it is blank. A heredoc
+// can follow an encoding prefix, as in "base64:<<EOF".
+//
+// If Field 4 starts with the "file:" marker, then the content
+// is copied from the file, which path is the rest of the field,
+// quoted same way as paths in Field 3. A relative path is
+// resolved against the Parser's Dir setting, which is the
+// working directory for the ParseReader function. The file
+// has to exist at the parsing time.
+//
+// If Field 4 starts with the "->" marker, then the entry is a
+// symbolic link, and the rest of the field after optional
+// spaces is the link target. The target follows the same
+// quotation rules as paths in Field 3. Time and permissions
+// of symbolic links are not applied. To have a file content
+// literally starting with "->", quote the content.
+//
+// If Field 4 starts with the "=>" marker, then the entry is a
+// hard link to an earlier entry, which path is the rest of
+// the field, quoted same way as paths in Field 3. Time and
+// permissions of hard links are not applied, as they are
+// shared with the earlier entry.
+//
+// ParseReader reports all problems found in the input at once,
+// with line numbers, columns and fields where the problems are.
 func ParseReader(f Fatalfable, config io.Reader) []*Node {
-
-	entries := make([]*Node, 0, 10)
-
-	scanner := bufio.NewScanner(config)
-	for scanner.Scan() {
-
-		mt, perm, name, content, err := parse(scanner.Text())
-		if err != nil {
-			if _, ok := err.(*emptyErr); ok {
-				continue
-			}
-			f.Fatalf("While parsing the file system node string %q: %q", scanner.Text(), err)
-		}
-
-		entries = append(entries, &Node{perm, mt, name, content})
-	}
-
-	err := scanner.Err()
-	if err != nil {
-		f.Fatalf("Errored scanning the io.Reader: %q", err)
-	}
-
-	return entries
+	return Parser{}.ParseReader(f, config)
 }
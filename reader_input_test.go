@@ -0,0 +1,598 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReaderEncodings(t *testing.T) {
+
+	binary := "\x00\x01binary\xff\xfe"
+
+	tree := "2001-01-01T01:01:01Z\t0600\tb64\tbase64:AAFiaW5hcnn//g==\n" +
+		"2001-01-01T01:01:01Z\t0600\tb64_spaced\tbase64:\"AAFia W5hc nn//g==\"\n" +
+		"2001-01-01T01:01:01Z\t0600\thex\thex:000162696e617279fffe\n" +
+		"2001-01-01T01:01:01Z\t0600\tgz\tgzip+base64:H4sIAAAAAAAC/2NgTMrMSyyq/P8PALP7ir0KAAAA\n" +
+		"2001-01-01T01:01:01Z\t0600\tliteral\t\"hex:00\"\n" +
+		"2001-01-01T01:01:01Z\t0600\tunknown\tkey:value\n"
+
+	expect := []string{binary, binary, binary, binary, "hex:00", "key:value"}
+
+	nodes := ParseReader(t, strings.NewReader(tree))
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	for i, n := range nodes {
+		data, err := ioutil.ReadFile(n.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expect[i] {
+			t.Errorf("Content mismatch, expected %q, got %q for %q", expect[i], data, n.name)
+		}
+	}
+}
+
+func TestParseReaderHeredoc(t *testing.T) {
+
+	tree := "2001-01-01T01:01:01Z\t0600\tplain\t<<EOF\n" +
+		"[section]\n" +
+		"\tkey = value\n" +
+		"\n" +
+		"EOF\n" +
+		"2001-01-01T01:01:01Z\t0600\tstripped\t<<-END\n" +
+		"\t\t[section]\n" +
+		"\t\t\tkey = value\n" +
+		"\n" +
+		"\t\tEND\n" +
+		"2001-01-01T01:01:01Z\t0600\tempty\t<<EOF\n" +
+		"EOF\n" +
+		"2001-01-01T01:01:01Z\t0600\tencoded\tbase64:<<EOF\n" +
+		"AAFiaW5h\n" +
+		"cnn//g==\n" +
+		"EOF\n" +
+		"2001-01-01T01:01:01Z\t0600\tafter\tdone\n"
+
+	expect := map[string]string{
+		"plain":    "[section]\n\tkey = value\n\n",
+		"stripped": "[section]\n\tkey = value\n\n",
+		"empty":    "",
+		"encoded":  "\x00\x01binary\xff\xfe",
+		"after":    "done",
+	}
+
+	nodes := ParseReader(t, strings.NewReader(tree))
+
+	if len(nodes) != len(expect) {
+		t.Fatalf("Expected %d nodes, got %d", len(expect), len(nodes))
+	}
+
+	for _, n := range nodes {
+		if n.body != expect[n.name] {
+			t.Errorf("Content mismatch, expected %q, got %q for %q", expect[n.name], n.body, n.name)
+		}
+	}
+
+	bad := []string{
+		"2001-01-01T01:01:01Z\t0600\tunclosed\t<<EOF\nline\n",
+		"2001-01-01T01:01:01Z\t0600\tunindented\t<<-EOF\nline\n\tEOF\n",
+		"2001-01-01T01:01:01Z\t0600\tnoterm\t<<\nline\n",
+	}
+
+	for _, tree := range bad {
+		_, err := Parser{}.parse(strings.SplitN(tree, "\n", 2)[0], lines(strings.SplitN(tree, "\n", 2)[1]), &defaults{})
+		if err == nil {
+			t.Errorf("Malformed heredoc passed parsing: %q", tree)
+		}
+	}
+}
+
+func lines(text string) func() (string, bool) {
+	ls := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	return func() (string, bool) {
+		if len(ls) == 0 {
+			return "", false
+		}
+		l := ls[0]
+		ls = ls[1:]
+		return l, true
+	}
+}
+
+func TestParseFileContentFromFile(t *testing.T) {
+
+	nodes := ParseFile(t, "testdata/parse_file_mocks/tree")
+
+	expect, err := ioutil.ReadFile("testdata/parse_file_mocks/payloads/text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	for _, name := range []string{"dir/copy.txt", "dir/quoted.txt"} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != string(expect) {
+			t.Errorf("Content mismatch, expected %q, got %q for %q", expect, data, name)
+		}
+	}
+
+	_, err = Parser{Dir: "testdata"}.parse("2001-01-01T01:01:01Z\t0600\tmissing\tfile:payloads/text.txt", nil, &defaults{})
+	if err == nil {
+		t.Errorf("A missing content file passed parsing")
+	}
+}
+
+func TestParseReaderRelativeTimes(t *testing.T) {
+
+	now := Rfc3339(t, "2020-03-10T12:00:00Z")
+
+	tree := "now\t0600\tnow\n" +
+		"-7d\t0600\tweek_ago\n" +
+		"now-90m\t0600\thour_and_half_ago\n" +
+		"+1w2d3h\t0600\tfuture\n" +
+		"now-1.5h\t0600\tfraction\n" +
+		"2001-01-01T01:01:01Z\t0600\tabsolute\n"
+
+	expect := []time.Time{
+		now,
+		now.Add(-7 * 24 * time.Hour),
+		now.Add(-90 * time.Minute),
+		now.Add(9*24*time.Hour + 3*time.Hour),
+		now.Add(-90 * time.Minute),
+		Rfc3339(t, "2001-01-01T01:01:01Z"),
+	}
+
+	nodes := Parser{Now: now}.ParseReader(t, strings.NewReader(tree))
+
+	for i, n := range nodes {
+		if !n.time.Equal(expect[i]) {
+			t.Errorf("Time mismatch, expected %v, got %v for %q", expect[i], n.time, n.name)
+		}
+	}
+
+	for _, field := range []string{"now-", "-", "-7x", "now7d", "-7d3"} {
+		_, err := Parser{Now: now}.timestamp(field)
+		if err == nil {
+			t.Errorf("Malformed relative time %q passed parsing", field)
+		}
+	}
+
+	before := time.Now()
+	nodes = ParseReader(t, strings.NewReader("-1h\t0600\tan_hour_ago\n"))
+	after := time.Now()
+
+	if nodes[0].time.Before(before.Add(-time.Hour)) || nodes[0].time.After(after.Add(-time.Hour)) {
+		t.Errorf("Time %v is not an hour before the parsing time %v", nodes[0].time, before)
+	}
+}
+
+func TestParseReaderDirectives(t *testing.T) {
+
+	tree := `
+# A comment line
+	# An indented comment line
+%time	2001-01-01T01:01:01Z
+%perm 0640
+%dirperm 0750
+
+dir/
+dir/file	content
+2002-02-02T02:02:02Z	dir/timed
+0600	dir/permitted
+2003-03-03T03:03:03Z	0500	dir/full/
+"0644"
+%perm 0600
+after
+nowhere
+`
+
+	expect := []*Node{
+		{perm: 0750, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "dir/"},
+		{perm: 0640, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "dir/file", body: "content"},
+		{perm: 0640, time: Rfc3339(t, "2002-02-02T02:02:02Z"), name: "dir/timed"},
+		{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "dir/permitted"},
+		{perm: 0500, time: Rfc3339(t, "2003-03-03T03:03:03Z"), name: "dir/full/"},
+		{perm: 0640, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "0644"},
+		{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "after"},
+		{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "nowhere"},
+	}
+
+	nodes := ParseReader(t, strings.NewReader(tree))
+
+	if len(nodes) != len(expect) {
+		t.Fatalf("Expected %d nodes, got %d", len(expect), len(nodes))
+	}
+
+	for i, n := range nodes {
+		e := expect[i]
+		if n.name != e.name || n.perm != e.perm || !n.time.Equal(e.time) || n.body != e.body {
+			t.Errorf("Node mismatch, expected %+v, got %+v", e, n)
+		}
+	}
+
+	bad := []string{
+		"file",
+		"0600\tfile",
+		"2001-01-01T01:01:01Z\tfile",
+		"%perm 0600 0700",
+		"%perm 644",
+		"%owner root",
+		"2001-01-01T01:01:01Z\t0600\tfile\tcontent\textra",
+		"2001-01-01T01:01:01Z\t0600",
+	}
+
+	for _, line := range bad {
+		_, err := Parser{}.parse(line, nil, &defaults{})
+		if _, ok := err.(*emptyErr); ok || err == nil {
+			t.Errorf("Malformed line passed parsing: %q", line)
+		}
+	}
+}
+
+func TestParseReaderSpecialBits(t *testing.T) {
+
+	tree := `
+2001-01-01T01:01:01Z	1777	src/
+2001-01-01T01:01:01Z	02750	src/shared/
+2001-01-01T01:01:01Z	04755	src/shared/tool	tool
+2001-01-01T01:01:01Z	0700	dst/
+`
+
+	expect := map[string]os.FileMode{
+		"src/":            0777 | os.ModeSticky,
+		"src/shared/":     0750 | os.ModeSetgid,
+		"src/shared/tool": 0755 | os.ModeSetuid,
+		"dst/":            0700,
+	}
+
+	nodes := ParseReader(t, strings.NewReader(tree))
+
+	for _, n := range nodes {
+		if n.perm != expect[n.name] {
+			t.Errorf("Expected %q mode %v, got %v", n.name, expect[n.name], n.perm)
+		}
+	}
+
+	for _, perm := range []string{"1777", "02750", "04755", "0640", "06000"} {
+		mode, err := permission(perm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if octal(mode) != "0"+strings.TrimPrefix(perm, "0") {
+			t.Errorf("Permissions %q formatted back as %q", perm, octal(mode))
+		}
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	for name, mode := range expect {
+		fi, err := os.Lstat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attrMode(fi.Mode()) != mode {
+			t.Errorf("Expected %q created with mode %v, got %v", name, mode, fi.Mode())
+		}
+	}
+
+	TreeCopy(t, "src", "dst")
+
+	if diffs := TreeDiff(t, "src", "dst", ModeRank, TimeRank); diffs != nil {
+		t.Errorf("Special bits are not preserved by copying: %v", diffs)
+	}
+}
+
+func TestParseReaderAccessTimes(t *testing.T) {
+
+	tree := `
+2001-01-01T01:01:01Z,2002-02-02T02:02:02Z	0700	src/
+2001-01-01T01:01:01Z,2003-03-03T03:03:03Z	0600	src/file	content
+2001-01-01T01:01:01Z	0600	src/same	content
+%time 2001-01-01T01:01:01Z,-1h
+0700	dst/
+0700	exp/
+2001-01-01T01:01:01Z,2003-03-03T03:03:03Z	0600	exp/file	content
+2001-01-01T01:01:01Z	0600	exp/same	content
+`
+
+	p := Parser{Now: Rfc3339(t, "2010-10-10T10:10:10Z")}
+	nodes := p.ParseReader(t, strings.NewReader(tree))
+
+	expect := []string{"2002-02-02T02:02:02Z", "2003-03-03T03:03:03Z", "", "2010-10-10T09:10:10Z"}
+	for i, at := range expect {
+		if at == "" && !nodes[i].atime.IsZero() || at != "" && !nodes[i].atime.Equal(Rfc3339(t, at)) {
+			t.Errorf("Expected %q access time %q, got %v", nodes[i].name, at, nodes[i].atime)
+		}
+	}
+
+	for _, bad := range []string{
+		"2001-01-01T01:01:01Z,\t0600\tf",
+		"2001-01-01T01:01:01Z,x\t0600\tf",
+		"2001-01-01T01:01:01Z,2002-02-02T02:02:02Z\t0777\tl\t-> f",
+		"2001-01-01T01:01:01Z\t0600\tf\n2001-01-01T01:01:01Z,2002-02-02T02:02:02Z\t0600\th\t=> f",
+	} {
+		if _, err := (Parser{}).Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Malformed access time passed parsing: %q", bad)
+		}
+	}
+
+	var buf strings.Builder
+	WriteNodes(t, &buf, nodes[1:3])
+	if !strings.HasPrefix(buf.String(), "2001-01-01T01:01:01Z,2003-03-03T03:03:03Z\t") {
+		t.Errorf("The access time is not written:\n%s", buf.String())
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	fi, err := os.Lstat("src/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if at, _, ok := statTimes(fi); !ok {
+		t.Skip("Access times are not available on the platform")
+	} else if !at.Equal(Rfc3339(t, "2003-03-03T03:03:03Z")) {
+		t.Errorf("Expected the access time of the created file %v, got %v", expect[1], at)
+	}
+
+	TreeCopy(t, "src", "dst")
+
+	// Reading the source files while copying may update their
+	// access times, so the copy is compared with a fresh tree
+	if diffs := TreeDiff(t, "dst", "exp", TimeRank, AtimeRank); diffs != nil {
+		t.Errorf("Access times are not preserved by copying: %v", diffs)
+	}
+
+	fips := MakeFipSlice(t, "exp/same", "exp/file")
+	if !ByAtime(fips[0], fips[1]) || ByAtime(fips[1], fips[0]) {
+		t.Error("Files with different access times are not told apart")
+	}
+
+	for _, n := range TreeSnapshot(t, "exp") {
+		if n.name == "file" && !n.atime.Equal(Rfc3339(t, expect[1])) {
+			t.Errorf("Expected the snapshot access time %v, got %v", expect[1], n.atime)
+		}
+	}
+}
+
+func TestParseReaderHardLinks(t *testing.T) {
+
+	tree := "2001-01-01T01:01:01Z\t0700\tsrc/\n" +
+		"2001-01-01T01:01:01Z\t0600\tsrc/one\tsame\n" +
+		"2001-01-01T01:01:01Z\t0600\tsrc/two\t=> src/one\n" +
+		"2001-01-01T01:01:01Z\t0600\tsrc/three\t=>\"src/one\"\n"
+
+	nodes := ParseReader(t, strings.NewReader(tree))
+
+	for _, n := range nodes[2:] {
+		if n.hard != "src/one" || n.body != "" || n.link != "" {
+			t.Errorf("Expected %q to be a hard link to \"src/one\", got %+v", n.name, n)
+		}
+	}
+
+	bad := []string{
+		"2001-01-01T01:01:01Z\t0700\tdir/\t=> src/one",
+		"2001-01-01T01:01:01Z\t0600\tf\t=>",
+		"2001-01-01T01:01:01Z\t0600\tf\t=> src/",
+	}
+
+	for _, input := range bad {
+		if _, err := (Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Malformed hard link passed parsing: %q", input)
+		}
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	if !os.SameFile(NewFileInfoPath(t, "src/one").FileInfo, NewFileInfoPath(t, "src/three").FileInfo) {
+		t.Error("Files \"src/one\" and \"src/three\" are not hard linked")
+	}
+
+	// Targets are resolved when the tree is created, so a
+	// missing target or a directory fails the creation
+	for _, input := range []string{
+		"2001-01-01T01:01:01Z\t0600\tsrc/missing\t=> src/none\n",
+		"2001-01-01T01:01:01Z\t0600\tsrc/to_dir\t=> src\n",
+	} {
+		fr := &fatalRecorder{}
+		TreeCreate(fr, ParseReader(t, strings.NewReader(input)))
+		if len(fr.msgs) == 0 {
+			t.Errorf("Creating a hard link with a bad target passed: %q", input)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tree := "2001-01-01T01:01:01Z\t0600\tgood\n" +
+		"2001-13-01T01:01:01Z\t0600\tbad_time\n" +
+		"# a comment\n" +
+		"2001-01-01T01:01:01Z\t0800\tbad_octal\n" +
+		"  2001-01-01T01:01:01Z\t0600\t\"unbalanced\n" +
+		"2001-01-01T01:01:01Z\t0600\tname\tcontent\textra\n" +
+		"no_defaults\n" +
+		"%bogus directive\n" +
+		"2001-01-01T01:01:01Z\t0600\talso_good\n" +
+		"2001-01-01T01:01:01Z\t0600\tunclosed\t<<EOF\n"
+
+	expect := []struct{ line, field, column int }{
+		{2, 1, 1},
+		{4, 2, 22},
+		{5, 3, 29},
+		{6, 5, 40},
+		{7, 1, 1},
+		{8, 0, 1},
+		{10, 4, 36},
+	}
+
+	nodes, err := Parser{}.Parse(strings.NewReader(tree))
+
+	if len(nodes) != 2 || nodes[0].name != "good" || nodes[1].name != "also_good" {
+		t.Errorf("Expected nodes from good lines only, got %+v", nodes)
+	}
+
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("Expected ParseErrors, got %v", err)
+	}
+
+	if len(errs) != len(expect) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expect), len(errs), errs)
+	}
+
+	for i, pe := range errs {
+		if pe.Line != expect[i].line || pe.Field != expect[i].field || pe.Column != expect[i].column {
+			t.Errorf("Expected error at line %d, field %d, column %d, got %v", expect[i].line, expect[i].field, expect[i].column, pe)
+		}
+	}
+
+	_, err = Parser{}.Parse(strings.NewReader(tree[:strings.Index(tree, "2001-13")]))
+	if err != nil {
+		t.Errorf("Correct tree description failed parsing: %v", err)
+	}
+
+	empty := []string{
+		"2001-01-01T01:01:01Z\t0644\t\"\"\n",
+		"2001-01-01T01:01:01Z\t0644\t\"\"\t->x\n",
+		"%perm 0644\n%time now\n\"\"\n",
+	}
+
+	for _, tree := range empty {
+		_, err := Parser{}.Parse(strings.NewReader(tree))
+		if errs, ok := err.(ParseErrors); !ok || len(errs) != 1 || errs[0].Field != 3 {
+			t.Errorf("Expected an empty name error in %q, got %v", tree, err)
+		}
+	}
+}
+
+type fatalRecorder struct {
+	msgs []string
+}
+
+func (fr *fatalRecorder) Fatalf(format string, v ...interface{}) {
+	fr.msgs = append(fr.msgs, fmt.Sprintf(format, v...))
+}
+
+func TestLoadTreeSections(t *testing.T) {
+
+	lib := "testdata/parse_file_mocks/library"
+
+	cases := []struct {
+		section string
+		names   []string
+	}{
+		{"", []string{"project/", "project/README"}},
+		{"plain", []string{"project/", "project/README"}},
+		{"configured", []string{"project/", "project/README", "project/config", "project/extra"}},
+	}
+
+	for _, tc := range cases {
+		nodes := LoadTree(t, lib, tc.section)
+
+		if len(nodes) != len(tc.names) {
+			t.Fatalf("Expected %d nodes in the section %q, got %d", len(tc.names), tc.section, len(nodes))
+		}
+
+		for i, n := range nodes {
+			if n.name != tc.names[i] {
+				t.Errorf("Expected node %q in the section %q, got %q", tc.names[i], tc.section, n.name)
+			}
+		}
+	}
+
+	nodes := LoadTree(t, lib, "configured")
+
+	expect := []*Node{
+		{perm: 0750, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "project/"},
+		{perm: 0640, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "project/README", body: "A common skeleton\n"},
+		{perm: 0600, time: Rfc3339(t, "2002-02-02T02:02:02Z"), name: "project/config", body: "key = value"},
+		{perm: 0600, time: Rfc3339(t, "2003-03-03T03:03:03Z"), name: "project/extra"},
+	}
+
+	for i, n := range nodes {
+		e := expect[i]
+		if n.name != e.name || n.perm != e.perm || !n.time.Equal(e.time) || n.body != e.body {
+			t.Errorf("Node mismatch, expected %+v, got %+v", e, n)
+		}
+	}
+
+	for _, section := range []string{"loop", "bad", "absent"} {
+		fr := &fatalRecorder{}
+		LoadTree(fr, lib, section)
+		if len(fr.msgs) == 0 {
+			t.Errorf("Loading a malformed section %q did not fail", section)
+		}
+	}
+}
+
+func TestIncludeQuotedPath(t *testing.T) {
+
+	root, cleanup := TempInitDir(t)
+	defer cleanup()
+
+	err := ioutil.WriteFile(root+"/with \"space\"", []byte("[part]\n2001-01-01T01:01:01Z\t0600\tincluded\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := Parser{Dir: root}.Parse(strings.NewReader("%include \"with \\\"space\\\"\" part\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 1 || nodes[0].name != "included" {
+		t.Errorf("Expected the included node, got %v", nodes)
+	}
+
+	for _, bad := range []string{"%include \"unbalanced", "%include \"escaped\\\""} {
+		if _, err := (Parser{Dir: root}).Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Malformed include passed parsing: %q", bad)
+		}
+	}
+}
+
+func TestParseSkipsOtherSections(t *testing.T) {
+
+	tree := `
+[broken]
+2001-01-01T01:01:01Z	0600	missing	file:no/such/file
+2001-01-01T01:01:01Z	0600
+%perm 644
+2001-01-01T01:01:01Z	0600	doc	<<END
+[good]
+END
+[good]
+2001-01-01T01:01:01Z	0600	fine	content
+`
+
+	nodes, err := Parser{Section: "good"}.Parse(strings.NewReader(tree))
+	if err != nil {
+		t.Fatalf("Problems in an unselected section are reported: %s", err)
+	}
+
+	if len(nodes) != 1 || nodes[0].name != "fine" {
+		t.Errorf("Expected only the \"fine\" node, got %v", nodes)
+	}
+
+	if _, err := (Parser{Section: "broken"}).Parse(strings.NewReader(tree)); err == nil {
+		t.Error("Problems in the selected section are not reported")
+	}
+}
@@ -101,8 +101,8 @@ func TempInitChdir(f Fatalfable) (string, func()) {
 }
 
 // TempCloneDir function creates a copy of an existing
-// directory with it's content - regular files only - for
-// holding temporary test files.
+// directory with it's content - regular files, directories,
+// and symbolic links - for holding temporary test files.
 //
 // The returned values are:
 //
@@ -118,14 +118,22 @@ func TempInitChdir(f Fatalfable) (string, func()) {
 // cleanup funcion is nil, and the temp folder is
 // expected to be already removed.
 //
-// The clone attempts to maintain the basic original Unix
-// permissions (9-bit only, from the rxwrwxrwx set).
+// The clone attempts to maintain the original Unix
+// permissions from the rxwrwxrwx set, along with the
+// setuid, setgid, and sticky bits.
 // If, however, the user does not have read permission
 // for a file, or read+execute permission for a directory,
 // then the clone process will naturally fail.
 func TempCloneDir(f Fatalfable, src string) (string, func()) {
+	return TempCloneDirExcept(f, src, nil)
+}
+
+// TempCloneDirExcept clones a temporary directory in the same
+// fashion as TempCloneDir, except for objects selected by the
+// match function, as TreeCopyExcept does.
+func TempCloneDirExcept(f Fatalfable, src string, match PathMatch) (string, func()) {
 	root, cleanup := TempInitDir(f)
-	TreeCopy(newFatalCleaner(f, cleanup), src, root)
+	TreeCopyExcept(newFatalCleaner(f, cleanup), src, root, match)
 	return root, cleanup
 }
 
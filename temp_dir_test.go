@@ -154,7 +154,7 @@ func TestTempCloneDir(t *testing.T) {
 		t.Errorf("Returned temporary path \"%s\" is not a directory", testRootDir)
 	}
 
-	diffs := TreeDiff(t, src, testRootDir, ByName, ByDir, BySize, ByPerm, ByTime, ByContent(t))
+	diffs := TreeDiff(t, src, testRootDir, DirRank, SizeRank, PermRank, TimeRank, ContentRank(t))
 
 	if diffs != nil {
 		t.Errorf("Trees at \"%s\" and \"%s\" differ unexpectedly: %v", src, testRootDir, diffs)
@@ -197,7 +197,7 @@ func TestTempCloneChdir(t *testing.T) {
 
 	src = filepath.Join(origWD, src)
 
-	diffs := TreeDiff(t, src, tempWD, ByName, ByDir, BySize, ByPerm, ByTime, ByContent(t))
+	diffs := TreeDiff(t, src, tempWD, DirRank, SizeRank, PermRank, TimeRank, ContentRank(t))
 
 	if diffs != nil {
 		t.Errorf("Trees at \"%s\" and \"%s\" differ unexpectedly: %v", src, tempWD, diffs)
@@ -229,13 +229,13 @@ func ExampleTempCreateChdir() {
 	lg := log.New(os.Stderr, "ExampleTempCreateChdir", log.LUTC|log.Ldate|log.Ltime)
 
 	nodes := []*Node{
-		&Node{0750, Rfc3339(lg, "2001-01-01T01:01:01Z"), "a/", ""},
-		&Node{0750, Rfc3339(lg, "2001-01-01T01:01:01Z"), "b/", ""},
-		&Node{0700, Rfc3339(lg, "2001-01-01T01:01:01Z"), "c.txt", "This is a two line\nfile with\ta tab\n"},
-		&Node{0700, Rfc3339(lg, "2001-01-01T01:01:01Z"), "d.txt", "A single line without tabs"},
-		&Node{0700, Rfc3339(lg, "2002-01-01T01:01:01Z"), "has\ttab/", ""},
-		&Node{0700, Rfc3339(lg, "2002-01-01T01:01:01Z"), "has\ttab/e.mb", "# Markdown...\n\n... also ***possible***\n"},
-		&Node{0700, Rfc3339(lg, "2002-01-01T01:01:01Z"), "\u263asmiles\u263a/", ""},
+		&Node{perm: 0750, time: Rfc3339(lg, "2001-01-01T01:01:01Z"), name: "a/", body: ""},
+		&Node{perm: 0750, time: Rfc3339(lg, "2001-01-01T01:01:01Z"), name: "b/", body: ""},
+		&Node{perm: 0700, time: Rfc3339(lg, "2001-01-01T01:01:01Z"), name: "c.txt", body: "This is a two line\nfile with\ta tab\n"},
+		&Node{perm: 0700, time: Rfc3339(lg, "2001-01-01T01:01:01Z"), name: "d.txt", body: "A single line without tabs"},
+		&Node{perm: 0700, time: Rfc3339(lg, "2002-01-01T01:01:01Z"), name: "has\ttab/", body: ""},
+		&Node{perm: 0700, time: Rfc3339(lg, "2002-01-01T01:01:01Z"), name: "has\ttab/e.mb", body: "# Markdown...\n\n... also ***possible***\n"},
+		&Node{perm: 0700, time: Rfc3339(lg, "2002-01-01T01:01:01Z"), name: "\u263asmiles\u263a/", body: ""},
 	}
 
 	_, cleanup := TempCreateChdir(lg, nodes)
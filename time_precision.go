@@ -0,0 +1,72 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// precisions are the timestamp precisions TimePrecision
+// checks for, finest first
+var precisions = []time.Duration{
+	time.Nanosecond,
+	10 * time.Nanosecond,
+	100 * time.Nanosecond,
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+}
+
+// TimePrecision finds the precision of modification times
+// kept by the filesystem of the directory. It sets a timestamp
+// with nanoseconds on a temporary file and reads it back. The
+// result is a power of ten between a nanosecond and a second,
+// or two seconds. If the timestamp is read back with an even
+// larger difference, then the difference is returned.
+//
+// Tests relying on exact timestamps may check that the
+// precision is a nanosecond to use ByTimeExact, or choose
+// a tolerance for the ByTimestamp comparator otherwise.
+func TimePrecision(f Fatalfable, dir string) time.Duration {
+
+	file, err := ioutil.TempFile(dir, "precision")
+	if err != nil {
+		f.Fatalf("Creating a file to probe timestamps in %q: %s", dir, err)
+	}
+
+	name := file.Name()
+	file.Close()
+	defer os.Remove(name)
+
+	set := time.Date(2001, 1, 1, 1, 1, 1, 123456789, time.UTC)
+
+	err = os.Chtimes(name, set, set)
+	if err != nil {
+		f.Fatalf("Setting timestamps of %q: %s", name, err)
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		f.Fatalf("Reading timestamps of %q: %s", name, err)
+	}
+
+	got := fi.ModTime()
+	for _, p := range precisions {
+		if got.Equal(set.Truncate(p)) || got.Equal(set.Round(p)) {
+			return p
+		}
+	}
+
+	if got.Before(set) {
+		return set.Sub(got)
+	}
+	return got.Sub(set)
+}
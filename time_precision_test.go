@@ -0,0 +1,64 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNanosecondTimes(t *testing.T) {
+
+	tree := `
+2001-01-01T01:01:01Z	0700	src/
+2001-01-01T01:01:01.123456789Z	0600	src/file	content
+2001-01-01T01:01:01Z	0700	dst/
+2001-01-01T01:01:01Z	0700	near/
+2001-01-01T01:01:01.123456788Z	0600	near/file	content
+`
+
+	nodes := ParseReader(t, strings.NewReader(tree))
+
+	if nodes[1].time.Nanosecond() != 123456789 {
+		t.Errorf("Expected nanoseconds in the parsed time, got %v", nodes[1].time)
+	}
+
+	var buf strings.Builder
+	WriteNodes(t, &buf, nodes[1:2])
+	if !strings.HasPrefix(buf.String(), "2001-01-01T01:01:01.123456789Z\t") {
+		t.Errorf("Nanoseconds are not written:\n%s", buf.String())
+	}
+
+	root, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	precision := TimePrecision(t, root)
+	if precision != time.Nanosecond {
+		t.Skipf("The temporary directory keeps timestamps with %v precision", precision)
+	}
+
+	fi, err := os.Lstat("src/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(nodes[1].time) {
+		t.Errorf("Expected the created file time %v, got %v", nodes[1].time, fi.ModTime())
+	}
+
+	TreeCopy(t, "src", "dst")
+
+	if diffs := TreeDiff(t, "src", "dst", TimeExactRank); diffs != nil {
+		t.Errorf("Nanoseconds are not preserved by copying: %v", diffs)
+	}
+
+	if diffs := TreeDiff(t, "src", "near", TimeRank); diffs != nil {
+		t.Errorf("Times a nanosecond apart differ within the ByTime slack: %v", diffs)
+	}
+
+	if diffs := TreeDiff(t, "src", "near", TimeExactRank); diffs == nil {
+		t.Error("Times a nanosecond apart are not told apart by ByTimeExact")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlTree is the TOML representation of a list of nodes
+type tomlTree struct {
+	Node []*jsonNode `toml:"node"`
+}
+
+// ParseTOML decodes a TOML tree description with the parser's
+// settings. See the ParseTOML function for the format.
+func (p Parser) ParseTOML(f Fatalfable, config io.Reader) []*Node {
+
+	var tree tomlTree
+
+	md, err := toml.DecodeReader(config, &tree)
+	if err != nil {
+		f.Fatalf("Decoding the TOML tree description: %q", err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		f.Fatalf("Unknown keys in the TOML tree description: %s", strings.Join(keys, ", "))
+	}
+
+	return p.fromJSONNodes(f, "TOML", tree.Node)
+}
+
+// ParseTOML decodes a TOML tree description into a list of
+// filesystem node data, same as ParseJSON does for JSON. Each
+// node is a table in the "node" array of tables, with the same
+// string keys as the ParseJSON fields, and with the "xattrs"
+// and "xattr_encodings" sub-tables:
+//
+//	[[node]]
+//	time = "2001-01-01T01:01:01Z"
+//	perm = "0640"
+//	name = "dir/text"
+//	content = """
+//	two
+//	lines
+//	"""
+//	[node.xattrs]
+//	"user.note" = "draft"
+//
+// Unknown keys are errors.
+func ParseTOML(f Fatalfable, config io.Reader) []*Node {
+	return Parser{}.ParseTOML(f, config)
+}
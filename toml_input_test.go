@@ -0,0 +1,128 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+
+	tree := `# a sample tree
+[[node]]
+time = "2001-01-01T01:01:01Z"
+perm = "0750"
+name = "dir/"
+
+[[node]]
+time = "2001-01-01T01:01:01Z"
+perm = '0640'
+name = "dir/text"
+content = """
+two
+lines
+"""
+
+[[node]]
+time = "2001-01-01T01:01:01Z"
+perm = "0600"
+name = "dir/bin"  # binary content
+content = "AAFiaW5hcnn//g=="
+encoding = "base64"
+
+[[node]]
+time = "2001-01-01T01:01:01Z"
+perm = "0600"
+name = "dir/copy"
+file = 'testdata/parse_file_mocks/payloads/text.txt'
+
+[node.xattrs]
+"user.note" = "quoted \"note\"\tand \u00e9"
+
+[[node]]
+name = "dir/link"
+link = "text"
+
+[[node]]
+name = "dir/hard"
+hard = "dir/text"
+`
+
+	nodes := ParseTOML(t, strings.NewReader(tree))
+
+	expect := ParseReader(t, strings.NewReader(
+		"2001-01-01T01:01:01Z\t0750\tdir/\n"+
+			"2001-01-01T01:01:01Z\t0640\tdir/text\t\"two\\nlines\\n\"\n"+
+			"2001-01-01T01:01:01Z\t0600\tdir/bin\thex:000162696e617279fffe\n"+
+			"2001-01-01T01:01:01Z\t0600\tdir/copy\tfile:testdata/parse_file_mocks/payloads/text.txt\n"+
+			"2001-01-01T01:01:01Z\t0777\tdir/link\t-> text\n"+
+			"2001-01-01T01:01:01Z\t0777\tdir/hard\t=> dir/text\n"))
+
+	if len(nodes) != len(expect) {
+		t.Fatalf("Expected %d nodes, got %d", len(expect), len(nodes))
+	}
+
+	for i, n := range nodes {
+		if !sameNode(n, expect[i]) {
+			t.Errorf("Node mismatch, expected %+v, got %+v", expect[i], n)
+		}
+	}
+
+	if note := nodes[3].xattrs["user.note"]; note != "quoted \"note\"\tand \u00e9" {
+		t.Errorf("Unexpected extended attribute value %q", note)
+	}
+
+	var buf bytes.Buffer
+	WriteTOML(t, &buf, nodes)
+	text := buf.String()
+
+	reparsed := ParseTOML(t, &buf)
+	if len(reparsed) != len(nodes) {
+		t.Fatalf("Expected %d nodes in a round trip, got %d in:\n%s", len(nodes), len(reparsed), text)
+	}
+
+	for i, n := range reparsed {
+		if !sameNode(n, nodes[i]) {
+			t.Errorf("Node changed in a round trip, expected %+v, got %+v", nodes[i], n)
+		}
+	}
+
+	if note := reparsed[3].xattrs["user.note"]; note != nodes[3].xattrs["user.note"] {
+		t.Errorf("Extended attribute changed in a round trip to %q", note)
+	}
+
+	nodes[0].xattrs = map[string]string{"user.bin": "\x00\xff\xfe"}
+	buf.Reset()
+	WriteTOML(t, &buf, nodes[:1])
+
+	if !strings.Contains(buf.String(), "[node.xattr_encodings]\n\"user.bin\" = \"base64\"\n") {
+		t.Errorf("Binary extended attribute is not encoded:\n%s", buf.String())
+	}
+
+	if bin := ParseTOML(t, &buf)[0].xattrs["user.bin"]; bin != "\x00\xff\xfe" {
+		t.Errorf("Binary extended attribute changed in a round trip to %q", bin)
+	}
+
+	bad := []string{
+		"[[node]]\ntime = \"2001-01-01T01:01:01Z\"\nperm = \"0640\"\n",
+		"[[node]]\nperm = \"0640\"\nname = \"no_time\"\n",
+		"[[node]]\ntime = 2001-01-01T01:01:01Z\nperm = \"0640\"\nname = \"bare_time\"\n",
+		"[[node]]\ntime = \"2001-01-01T01:01:01Z\"\nperm = 0o640\nname = \"bare_perm\"\n",
+		"[[node]]\ntime = \"2001-01-01T01:01:01Z\"\nperm = \"0640\"\nname = \"f\"\nowner = \"root\"\n",
+		"[[node]]\ntime = \"2001-01-01T01:01:01Z\"\nperm = \"0640\"\nname = \"f\"\nname = \"g\"\n",
+		"[[node]]\ntime = \"2001-01-01T01:01:01Z\"\nperm = \"0640\"\nname = \"unterminated\n",
+		"[[entry]]\ntime = \"2001-01-01T01:01:01Z\"\nperm = \"0640\"\nname = \"f\"\n",
+		"[[node]]\nname = \"d/\"\nlink = \"y\"\n",
+	}
+
+	for _, tree := range bad {
+		fr := &fatalRecorder{}
+		ParseTOML(fr, strings.NewReader(tree))
+		if len(fr.msgs) == 0 {
+			t.Errorf("Malformed TOML tree description passed parsing: %s", tree)
+		}
+	}
+}
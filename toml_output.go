@@ -0,0 +1,30 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io"
+
+	"github.com/BurntSushi/toml"
+)
+
+// WriteTOML encodes the nodes into the TOML tree description
+// format, as read by ParseTOML. Content and extended
+// attributes' values, which are not valid UTF-8, are encoded
+// as base64.
+func WriteTOML(f Fatalfable, w io.Writer, nodes []*Node) {
+
+	tree := tomlTree{Node: make([]*jsonNode, len(nodes))}
+	for i, n := range nodes {
+		tree.Node[i] = toJSON(n)
+	}
+
+	enc := toml.NewEncoder(w)
+	enc.Indent = ""
+
+	err := enc.Encode(tree)
+	if err != nil {
+		f.Fatalf("Encoding the TOML tree description: %q", err)
+	}
+}
@@ -8,16 +8,31 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
-// TreeCopy duplicates redular files and directories from
-// inside the source directory into an existing destination
-// directory.
+// TreeCopy duplicates redular files, directories, and
+// symbolic links from inside the source directory into an
+// existing destination directory. Symbolic links are copied
+// as links with the same targets, without dereferencing.
+// Regular files hard-linked to each other inside the source
+// directory are hard-linked the same way in the destination.
+// Permissions, including the setuid, setgid, and sticky bits,
+// modification and access times, and user extended attributes
+// are preserved. Access times are as they were before copying.
 func TreeCopy(f Fatalfable, src, dst string) {
+	TreeCopyExcept(f, src, dst, nil)
+}
+
+// TreeCopyExcept copies the tree the same way as TreeCopy
+// does, except for objects selected by the match function,
+// which are not copied. A nil match copies everything.
+func TreeCopyExcept(f Fatalfable, src, dst string, match PathMatch) {
 
 	srcClean := filepath.Clean(src)
 	srcLen := len(srcClean)
 	dirs := make([]*Node, 0)
+	copied := make(map[fileID]string)
 
 	err := filepath.Walk(
 		srcClean,
@@ -27,10 +42,27 @@ func TreeCopy(f Fatalfable, src, dst string) {
 				return er
 			}
 
+			if skip, err := matchRel(match, srcClean, fn, fi); skip || err != nil {
+				return err
+			}
+
 			dest := filepath.Join(dst, fn[srcLen:])
 
 			if fi.Mode().IsRegular() {
 
+				if id, nlink, ok := inode(fi); ok && nlink > 1 {
+
+					if first, seen := copied[id]; seen {
+						err := os.Link(first, dest)
+						if err != nil {
+							return fmt.Errorf("Linking %q to %q: %s", dest, first, err)
+						}
+						return nil
+					}
+
+					copied[id] = dest
+				}
+
 				srcf, err := os.Open(fn)
 				if err != nil {
 					return fmt.Errorf("Opening the sorce file %q: %s", fn, err)
@@ -56,13 +88,18 @@ func TreeCopy(f Fatalfable, src, dst string) {
 					return fmt.Errorf("Closing the dest file %q: %s", dest, err)
 				}
 
-				err = os.Chmod(dest, fi.Mode())
+				err = copyXattrs(fn, dest)
+				if err != nil {
+					return err
+				}
+
+				err = os.Chmod(dest, attrMode(fi.Mode()))
 				if err != nil {
 					return fmt.Errorf("Setting permissions on %q: %s", dest, err)
 				}
 
 				destMT := fi.ModTime()
-				err = os.Chtimes(dest, destMT, destMT)
+				err = os.Chtimes(dest, accessTime(fi), destMT)
 				if err != nil {
 					return fmt.Errorf("Setting timestamp %s on %q: %s", destMT, dest, err)
 				}
@@ -70,13 +107,33 @@ func TreeCopy(f Fatalfable, src, dst string) {
 				return nil
 			}
 
+			if fi.Mode()&os.ModeSymlink != 0 {
+
+				target, err := os.Readlink(fn)
+				if err != nil {
+					return fmt.Errorf("Reading the symlink %q: %s", fn, err)
+				}
+
+				err = os.Symlink(target, dest)
+				if err != nil {
+					return fmt.Errorf("Creating symlink %q to %q: %s", dest, target, err)
+				}
+
+				return nil
+			}
+
 			if fi.Mode().IsDir() {
 
-				dirs = append(dirs, &Node{fi.Mode().Perm(), fi.ModTime(), dest, ""})
+				dirs = append(dirs, &Node{perm: attrMode(fi.Mode()), time: fi.ModTime(), atime: accessTime(fi), name: dest})
 				err := os.Mkdir(dest, 0700)
 				if err != nil {
 					return fmt.Errorf("Creating dir %q: %s", dest, err)
 				}
+
+				err = copyXattrs(fn, dest)
+				if err != nil {
+					return err
+				}
 			}
 			return nil
 		})
@@ -88,12 +145,22 @@ func TreeCopy(f Fatalfable, src, dst string) {
 	for i := len(dirs) - 1; i >= 0; i-- {
 		err := os.Chmod(dirs[i].name, dirs[i].perm)
 		if err != nil {
-			f.Fatalf("Setting permissions on %q to %o: %s", dirs[i].name, dirs[i].perm, err)
+			f.Fatalf("Setting permissions on %q to %s: %s", dirs[i].name, octal(dirs[i].perm), err)
 		}
 
-		err = os.Chtimes(dirs[i].name, dirs[i].time, dirs[i].time)
+		err = os.Chtimes(dirs[i].name, dirs[i].accessTime(), dirs[i].time)
 		if err != nil {
 			f.Fatalf("Setting timestamp %s on %q: %s", dirs[i].time, dirs[i].name, err)
 		}
 	}
 }
+
+// accessTime returns the access time from the file info,
+// or the modification time where access times are not
+// available
+func accessTime(fi os.FileInfo) time.Time {
+	if atime, _, ok := statTimes(fi); ok {
+		return atime
+	}
+	return fi.ModTime()
+}
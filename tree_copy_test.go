@@ -4,21 +4,23 @@
 package fst // import "go.didenko.com/fst"
 
 import (
+	"os"
+	"strings"
 	"testing"
 )
 
 func TestTreeCopy(t *testing.T) {
 
 	nodes := []*Node{
-		&Node{0700, Rfc3339(t, "2001-01-01T01:01:01Z"), "src/", ""},
-		&Node{0550, Rfc3339(t, "2001-01-01T01:01:01Z"), "src/a/", ""},
-		&Node{0700, Rfc3339(t, "2099-01-01T01:01:01Z"), "src/a/b/", ""},
-		&Node{0640, Rfc3339(t, "2001-01-01T01:01:01Z"), "src/c.txt", "This is a two line\nfile with\ta tab\n"},
-		&Node{0600, Rfc3339(t, "2001-01-01T01:01:01Z"), "src/d.txt", "A single line without tabs"},
-		&Node{0700, Rfc3339(t, "2002-01-01T01:01:01Z"), "src/has\ttab/", ""},
-		&Node{0440, Rfc3339(t, "2002-01-01T01:01:01Z"), "src/has\ttab/e.mb", "# Markdown...\n\n... also ***possible***\n"},
-		&Node{0700, Rfc3339(t, "2002-01-01T01:01:01Z"), "src/\u10077heavy quoted\u10078/", ""},
-		&Node{0700, Rfc3339(t, "2001-01-01T01:01:01Z"), "dst/", ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/", body: ""},
+		&Node{perm: 0550, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/a/", body: ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2099-01-01T01:01:01Z"), name: "src/a/b/", body: ""},
+		&Node{perm: 0640, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/c.txt", body: "This is a two line\nfile with\ta tab\n"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/d.txt", body: "A single line without tabs"},
+		&Node{perm: 0700, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "src/has\ttab/", body: ""},
+		&Node{perm: 0440, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "src/has\ttab/e.mb", body: "# Markdown...\n\n... also ***possible***\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "src/\u10077heavy quoted\u10078/", body: ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "dst/", body: ""},
 	}
 
 	_, cleanup := TempCreateChdir(t, nodes)
@@ -26,9 +28,76 @@ func TestTreeCopy(t *testing.T) {
 
 	TreeCopy(t, "src", "dst")
 
-	diffs := TreeDiff(t, "src", "dst", ByName, ByDir, BySize, ByPerm, ByTime, ByContent(t))
+	diffs := TreeDiff(t, "src", "dst", DirRank, SizeRank, PermRank, TimeRank, ContentRank(t))
 
 	if diffs != nil {
 		t.Errorf("Trees at \"%s\" and \"%s\" differ unexpectedly: %v", "src", "dst", diffs)
 	}
 }
+
+func TestTreeCopySymlinks(t *testing.T) {
+
+	nodes := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/a/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "src/a/b.txt", body: "b"},
+		&Node{name: "src/to_file", link: "a/b.txt"},
+		&Node{name: "src/to_dir", link: "a"},
+		&Node{name: "src/dangling", link: "nowhere"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "dst/"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	TreeCopy(t, "src", "dst")
+
+	diffs := TreeDiff(t, "src", "dst", DirRank, SymlinkTargetRank, SizeRank, PermRank, TimeRank, ContentRank(t))
+
+	if diffs != nil {
+		t.Errorf("Trees at \"%s\" and \"%s\" differ unexpectedly: %v", "src", "dst", diffs)
+	}
+
+	fip := NewFileInfoPath(t, "dst/to_dir")
+	if fip.IsDir() || fip.Target() != "a" {
+		t.Errorf("Symlink \"dst/to_dir\" is not preserved: dir:%v, link:%q", fip.IsDir(), fip.Target())
+	}
+}
+
+func TestTreeCopyHardLinks(t *testing.T) {
+
+	tree := "2001-01-01T01:01:01Z\t0700\tsrc/\n" +
+		"2001-01-01T01:01:01Z\t0700\tsrc/a/\n" +
+		"2001-01-01T01:01:01Z\t0600\tsrc/a/one\tsame\n" +
+		"2001-01-01T01:01:01Z\t0600\tsrc/a/two\t=> src/a/one\n" +
+		"2001-01-01T01:01:01Z\t0600\tsrc/three\t=>src/a/one\n" +
+		"2001-01-01T01:01:01Z\t0600\tsrc/other\tsame\n" +
+		"2001-01-01T01:01:01Z\t0700\tdst/\n" +
+		"2001-01-01T01:01:01Z\t0700\tdup/\n" +
+		"2001-01-01T01:01:01Z\t0700\tdup/a/\n" +
+		"2001-01-01T01:01:01Z\t0600\tdup/a/one\tsame\n" +
+		"2001-01-01T01:01:01Z\t0600\tdup/a/two\tsame\n" +
+		"2001-01-01T01:01:01Z\t0600\tdup/three\tsame\n" +
+		"2001-01-01T01:01:01Z\t0600\tdup/other\tsame\n"
+
+	_, cleanup := TempCreateChdir(t, ParseReader(t, strings.NewReader(tree)))
+	defer cleanup()
+
+	TreeCopy(t, "src", "dst")
+
+	diffs := TreeDiff(t, "src", "dst", DirRank, SizeRank, PermRank, TimeRank, HardLinksRank, ContentRank(t))
+
+	if diffs != nil {
+		t.Errorf("Trees at \"%s\" and \"%s\" differ unexpectedly: %v", "src", "dst", diffs)
+	}
+
+	if !os.SameFile(NewFileInfoPath(t, "dst/three").FileInfo, NewFileInfoPath(t, "dst/a/one").FileInfo) {
+		t.Errorf("Files \"dst/three\" and \"dst/a/one\" are not hard linked")
+	}
+
+	diffs = TreeDiff(t, "src", "dup", DirRank, SizeRank, HardLinksRank)
+
+	if diffs == nil {
+		t.Errorf("Trees at \"%s\" and \"%s\" passed as equivalent despite different hard links", "src", "dup")
+	}
+}
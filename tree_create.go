@@ -3,12 +3,16 @@
 
 package fst // import "go.didenko.com/fst"
 import (
+	"io"
 	"os"
 )
 
 // TreeCreate creates the filesystem objects provided in the
 // slice of Node pointers, where Nodes describe the objects
-// to be created.
+// to be created. Symbolic links are created as provided,
+// without checking that their targets exist. Hard links
+// are created to files from earlier entries. Content of
+// files referencing other files is copied from them.
 //
 // It is up to the caller to deal with conflicting file and
 // directory names in the input. TreeCreate processes
@@ -28,6 +32,20 @@ func TreeCreate(f Fatalfable, entries []*Node) {
 			continue
 		}
 
+		if e.link != "" {
+			if err := os.Symlink(e.link, e.name); err != nil {
+				f.Fatalf("While making symlink %q to %q: %s", e.name, e.link, err)
+			}
+			continue
+		}
+
+		if e.hard != "" {
+			if err := os.Link(e.hard, e.name); err != nil {
+				f.Fatalf("While making hard link %q to %q: %s", e.name, e.hard, err)
+			}
+			continue
+		}
+
 		fl, err := os.Create(e.name)
 		if err != nil {
 			f.Fatalf("While creating the file %q: %s", e.name, err)
@@ -40,6 +58,19 @@ func TreeCreate(f Fatalfable, entries []*Node) {
 			}
 		}
 
+		if e.from != "" {
+			src, err := os.Open(e.from)
+			if err != nil {
+				f.Fatalf("While opening file %q content source %q: %s", e.name, e.from, err)
+			}
+
+			_, err = io.Copy(fl, src)
+			src.Close()
+			if err != nil {
+				f.Fatalf("While copying file %q content from %q: %s", e.name, e.from, err)
+			}
+		}
+
 		err = fl.Close()
 		if err != nil {
 			f.Fatalf("While colsing file %q: %s", e.name, err)
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -44,13 +45,13 @@ func match(t *testing.T, f *tcase, fi os.FileInfo) {
 func TestTreeCreateFromReader(t *testing.T) {
 
 	nodes := []*Node{
-		&Node{0150, Rfc3339(t, "2001-01-01T01:01:01Z"), "aaa/", ""},
-		&Node{0700, Rfc3339(t, "2099-01-01T01:01:01Z"), "aaa/bbb/", ""},
-		&Node{0700, Rfc3339(t, "2001-01-01T01:01:01Z"), "c.txt", "This is a two line\nfile with\ta tab\n"},
-		&Node{0700, Rfc3339(t, "2001-01-01T01:01:01Z"), "d.txt", "A single line without tabs"},
-		&Node{0700, Rfc3339(t, "2002-01-01T01:01:01Z"), "has\ttab/", ""},
-		&Node{0700, Rfc3339(t, "2002-01-01T01:01:01Z"), "has\ttab/e.mb", "# Markdown...\n\n... also ***possible***\n"},
-		&Node{0700, Rfc3339(t, "2002-01-01T01:01:01Z"), "\u10077heavy quoted\u10078/", ""},
+		&Node{perm: 0150, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "aaa/", body: ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2099-01-01T01:01:01Z"), name: "aaa/bbb/", body: ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "c.txt", body: "This is a two line\nfile with\ta tab\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "d.txt", body: "A single line without tabs"},
+		&Node{perm: 0700, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "has\ttab/", body: ""},
+		&Node{perm: 0700, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "has\ttab/e.mb", body: "# Markdown...\n\n... also ***possible***\n"},
+		&Node{perm: 0700, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "\u10077heavy quoted\u10078/", body: ""},
 	}
 
 	expect := []tcase{
@@ -139,3 +140,34 @@ func TestTreeCreateApi(t *testing.T) {
 		match(t, &tc, fi)
 	}
 }
+
+func TestTreeCreateSymlinks(t *testing.T) {
+
+	tree := "2001-01-01T01:01:01Z\t0700\tdir/\n" +
+		"2001-01-01T01:01:01Z\t0600\tdir/file\tcontent\n" +
+		"2001-01-01T01:01:01Z\t0777\tto_file\t-> dir/file\n" +
+		"2001-01-01T01:01:01Z\t0777\tto_dir\t->dir\n" +
+		"2001-01-01T01:01:01Z\t0777\tdangling\t-> \"no\\tsuch\"\n" +
+		"2001-01-01T01:01:01Z\t0600\tnot_link\t\"-> dir\"\n"
+
+	_, cleanup := TempCreateChdir(t, ParseReader(t, strings.NewReader(tree)))
+	defer cleanup()
+
+	links := map[string]string{
+		"to_file":  "dir/file",
+		"to_dir":   "dir",
+		"dangling": "no\tsuch",
+	}
+
+	for name, expect := range links {
+		target, err := os.Readlink(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != expect {
+			t.Errorf("Symlink %q points to %q instead of %q", name, target, expect)
+		}
+	}
+
+	match(t, &tcase{time.Date(2001, time.January, 1, 1, 1, 1, 0, time.UTC), 0600, "not_link", "-> dir"}, NewFileInfoPath(t, "not_link"))
+}
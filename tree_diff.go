@@ -7,78 +7,210 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // TreeDiff produces a slice of human-readable notes about
 // recursive differences between two directory trees on a
-// filesystem. Only plan directories and plain files are
-// compared in the tree. Specific comparisons are determined
-// By the variadic slice of FileRank functions, like the
-// ones in this package. A commonly used set of comparators
-// is ByName, ByDir, BySize, and ByContent
-func TreeDiff(f Fatalfable, a string, b string, comps ...FileRank) []string {
+// filesystem. Only plan directories, plain files, and
+// symbolic links are compared in the tree. Symbolic links
+// are not followed. Objects are paired by their paths
+// relative to the trees' roots, and then specific comparisons
+// are determined by the variadic slice of ranks, like the ones
+// in this package. A commonly used set of ranks is DirRank,
+// SizeRank, and ContentRank. The notes are a rendering of the
+// TreeCompare result, and list names of all ranks telling
+// paired objects apart.
+func TreeDiff(f Fatalfable, a string, b string, ranks ...Rank) []string {
+	return TreeCompare(f, a, b, ranks...).Strings()
+}
+
+// TreeDiffExcept produces notes the same way as TreeDiff does,
+// leaving out objects selected by the match function in either
+// tree. A nil match compares everything.
+func TreeDiffExcept(f Fatalfable, a string, b string, match PathMatch, ranks ...Rank) []string {
+	return TreeCompareExcept(f, a, b, match, ranks...).Strings()
+}
+
+// TreeCompare finds recursive differences between two
+// directory trees the same way as TreeDiff does, and returns
+// them as a DiffResult for programmatic inspection. Objects
+// at the same relative path in both trees are reported as
+// Changed if any rank tells them apart, in which case names
+// of all such ranks are listed in the entry's Attrs.
+func TreeCompare(f Fatalfable, a string, b string, ranks ...Rank) *DiffResult {
+	return TreeCompareExcept(f, a, b, nil, ranks...)
+}
+
+// TreeCompareExcept finds differences the same way as
+// TreeCompare does, leaving out objects selected by the
+// match function in either tree
+func TreeCompareExcept(f Fatalfable, a string, b string, match PathMatch, ranks ...Rank) *DiffResult {
+	return TreeCompareWith(f, a, b, TreeCompareOptions{Match: match}, ranks...)
+}
+
+// TreeCompareOptions tune the TreeCompareWith function. The
+// Match selects objects to leave out in either tree, same as
+// in TreeCompareExcept. The ContentDiffLimit is the largest
+// size of files in bytes, for which differences of content
+// are shown. Larger files are only noted as differing. Zero
+// sets the default limit of 64KiB, and a negative limit
+// disables content reports altogether. The HashWorkers is
+// the number of goroutines hashing files before comparing them
+// with ranks made by HashRank. Zero or less uses as
+// many goroutines as there are CPUs.
+type TreeCompareOptions struct {
+	Match            PathMatch
+	ContentDiffLimit int64
+	HashWorkers      int
+}
+
+// TreeCompareWith finds differences the same way as
+// TreeCompare does, as tuned by the options
+func TreeCompareWith(f Fatalfable, a string, b string, opts TreeCompareOptions, ranks ...Rank) *DiffResult {
+
+	limit := opts.ContentDiffLimit
+	if limit == 0 {
+		limit = defaultContentDiffLimit
+	}
 
-	listA := collectFileInfo(f, a)
-	listB := collectFileInfo(f, b)
+	listA := collectRelative(f, a, opts.Match)
+	listB := collectRelative(f, b, opts.Match)
 
-	onlyA, onlyB := collectDifferent(listA, listB, comps...)
+	paths := make([]string, 0, len(listA)+len(listB))
+	for rel := range listA {
+		paths = append(paths, rel)
+	}
+	for rel := range listB {
+		if _, ok := listA[rel]; !ok {
+			paths = append(paths, rel)
+		}
+	}
 
-	var diags []string
+	sort.Slice(paths, func(i, j int) bool {
+		return walkOrder(paths[i]) < walkOrder(paths[j])
+	})
 
-	if len(onlyA) > 0 {
-		diagA := fmt.Sprintf("Unique items from \"%s\": \n", a)
-		for _, fi := range onlyA {
-			diagA = diagA + fmt.Sprintf("dir:%v, sz:%v, mode:%v, time:%v, name: %v\n", fi.IsDir(), fi.Size(), fi.Mode(), fi.ModTime(), fi.Name())
+	for _, rank := range ranks {
+		if rank.hash {
+			hashFiles(f, pairedFiles(paths, listA, listB), opts.HashWorkers)
+			break
 		}
-		diags = append(diags, diagA)
 	}
-	if len(onlyB) > 0 {
-		diagB := fmt.Sprintf("Unique items from \"%s\": \n", b)
-		for _, fi := range onlyB {
-			diagB = diagB + fmt.Sprintf("dir:%v, sz:%v, mode:%v, time:%v, name: %v\n", fi.IsDir(), fi.Size(), fi.Mode(), fi.ModTime(), fi.Name())
+
+	result := &DiffResult{Left: a, Right: b, Entries: make([]*DiffEntry, 0)}
+
+	for _, rel := range paths {
+		left, right := listA[rel], listB[rel]
+
+		switch {
+		case right == nil:
+			result.Entries = append(result.Entries, &DiffEntry{Path: rel, Kind: OnlyLeft, Left: left})
+		case left == nil:
+			result.Entries = append(result.Entries, &DiffEntry{Path: rel, Kind: OnlyRight, Right: right})
+		default:
+			differing := differingRanks(left, right, ranks...)
+			if len(differing) == 0 {
+				continue
+			}
+
+			entry := &DiffEntry{Path: rel, Kind: Changed, Left: left, Right: right, Attrs: rankNames(differing)}
+			for _, rank := range differing {
+				if rank.content {
+					entry.Content = contentDiff(f, left, right, limit)
+					break
+				}
+			}
+			result.Entries = append(result.Entries, entry)
 		}
-		diags = append(diags, diagB)
 	}
 
-	return diags
+	return result
 }
 
-// collectDifferent forms file information slices for files
-// unique to either left or right collections. It is based
-// on a modified algorithm from the go.didenko.com/slops package
-func collectDifferent(left, right []*FileInfoPath, comps ...FileRank) (onlyLeft, onlyRight []*FileInfoPath) {
-
-	onlyLeft = make([]*FileInfoPath, 0)
-	onlyRight = make([]*FileInfoPath, 0)
+// pairedFiles lists file information for objects found at
+// the same relative paths in both trees
+func pairedFiles(paths []string, listA, listB map[string]*FileInfoPath) []*FileInfoPath {
+	var fips []*FileInfoPath
+	for _, rel := range paths {
+		if left, right := listA[rel], listB[rel]; left != nil && right != nil {
+			fips = append(fips, left, right)
+		}
+	}
+	return fips
+}
 
-	for l, r := 0, 0; l < len(left) || r < len(right); {
+// collectRelative maps paths relative to the tree's root to
+// the file information collected from the tree
+func collectRelative(f Fatalfable, dir string, match PathMatch) map[string]*FileInfoPath {
+	list := collectFileInfo(f, dir, match)
+	byPath := make(map[string]*FileInfoPath, len(list))
+	for _, fip := range list {
+		byPath[relPath(f, dir, fip)] = fip
+	}
+	return byPath
+}
 
-		if r < len(right) && (l == len(left) || Less(right[r], left[l], comps...)) {
-			onlyRight = append(onlyRight, right[r])
-			r++
-			continue
+// differingRanks lists the ranks, which tell the two
+// objects apart in either direction
+func differingRanks(left, right *FileInfoPath, ranks ...Rank) []Rank {
+	var differing []Rank
+	for _, rank := range ranks {
+		if rank.Less(left, right) || rank.Less(right, left) {
+			differing = append(differing, rank)
 		}
+	}
+	return differing
+}
 
-		if l < len(left) && (r == len(right) || Less(left[l], right[r], comps...)) {
-			onlyLeft = append(onlyLeft, left[l])
-			l++
-			continue
+// rankNames lists names of the ranks, leaving out unnamed ones
+func rankNames(ranks []Rank) []string {
+	var names []string
+	for _, rank := range ranks {
+		if rank.Name != "" {
+			names = append(names, rank.Name)
 		}
+	}
+	return names
+}
 
-		l++
-		r++
+func relPath(f Fatalfable, root string, fi *FileInfoPath) string {
+	rel, err := filepath.Rel(root, fi.Path())
+	if err != nil {
+		f.Fatalf("Relating %q to the tree %q: %s", fi.Path(), root, err)
 	}
+	return rel
+}
+
+// walkOrder makes relative paths sort the same way as
+// filepath.Walk visits them, with a directory's content
+// right after the directory itself
+func walkOrder(rel string) string {
+	return strings.Replace(rel, string(filepath.Separator), "\x00", -1)
+}
 
-	return onlyLeft, onlyRight
+func describe(rel string, fi *FileInfoPath) string {
+	desc := fmt.Sprintf("dir:%v, sz:%v, mode:%v, time:%v, name: %v", fi.IsDir(), fi.Size(), fi.Mode(), fi.ModTime(), rel)
+	if isSymlink(fi) {
+		desc = desc + fmt.Sprintf(", link: %v", fi.Target())
+	}
+	if len(fi.HardLinks()) > 0 {
+		desc = desc + fmt.Sprintf(", hard links: %v", strings.Join(fi.HardLinks(), ", "))
+	}
+	return desc + "\n"
 }
 
-func collectFileInfo(f Fatalfable, dir string) []*FileInfoPath {
+func collectFileInfo(f Fatalfable, dir string, match PathMatch) []*FileInfoPath {
 
 	list := make([]*FileInfoPath, 0)
 
-	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
 		if err == nil && path != dir {
-			list = append(list, &FileInfoPath{f, path})
+			if skip, err := matchRel(match, dir, path, fi); skip || err != nil {
+				return err
+			}
+			list = append(list, newFileInfoPath(f, fi, path))
 		}
 		return err
 	})
@@ -86,5 +218,29 @@ func collectFileInfo(f Fatalfable, dir string) []*FileInfoPath {
 	if err != nil {
 		f.Fatalf("Collecting file info in the tree %q: %s", dir, err)
 	}
+
+	groups := make(map[fileID][]*FileInfoPath)
+	for _, fip := range list {
+		if id, nlink, ok := inode(fip); ok && nlink > 1 && fip.Mode().IsRegular() {
+			groups[id] = append(groups[id], fip)
+		}
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		links := make([]string, len(group))
+		for i, fip := range group {
+			links[i] = relPath(f, dir, fip)
+		}
+		sort.Strings(links)
+
+		for _, fip := range group {
+			fip.links = links
+		}
+	}
+
 	return list
 }
@@ -0,0 +1,126 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffKind tells how a filesystem object differs between
+// two compared trees
+type DiffKind int
+
+const (
+	// OnlyLeft marks an object found only in the left tree
+	OnlyLeft DiffKind = iota + 1
+
+	// OnlyRight marks an object found only in the right tree
+	OnlyRight
+
+	// Changed marks an object found at the same relative
+	// path in both trees, but with different attributes
+	Changed
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case OnlyLeft:
+		return "only left"
+	case OnlyRight:
+		return "only right"
+	case Changed:
+		return "changed"
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// DiffEntry describes a single difference between two trees.
+// The Path is relative to the compared trees' roots. The Left
+// and Right fields are nil when the object is absent from the
+// respective tree. For Changed entries the Attrs field lists
+// names of differing attributes, which are the names of ranks
+// which told the objects apart, like "size" for a Rank of
+// BySize. Unnamed ranks are not listed. If a rank made by
+// ContentRank tells the objects apart, the Content field shows
+// how their content differs, as a unified diff for text files
+// or as a hex dump around the first differing byte for other
+// files, subject to the TreeCompareOptions.ContentDiffLimit.
+type DiffEntry struct {
+	Path    string
+	Kind    DiffKind
+	Left    *FileInfoPath
+	Right   *FileInfoPath
+	Attrs   []string
+	Content string
+}
+
+// DiffResult holds differences between the Left and Right
+// trees as found by TreeCompare
+type DiffResult struct {
+	Left    string
+	Right   string
+	Entries []*DiffEntry
+}
+
+// Equal reports whether no differences were found
+func (dr *DiffResult) Equal() bool {
+	return len(dr.Entries) == 0
+}
+
+// Only returns the entries of the given kind
+func (dr *DiffResult) Only(kind DiffKind) []*DiffEntry {
+	var entries []*DiffEntry
+	for _, e := range dr.Entries {
+		if e.Kind == kind {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Strings renders the result as human-readable notes: one
+// for each tree with objects absent from the other tree, and
+// one for objects changed between the trees, listing the
+// differing attributes. It returns nil if no differences
+// were found.
+func (dr *DiffResult) Strings() []string {
+
+	var diags []string
+
+	var diagL, diagR, diagC string
+	for _, e := range dr.Entries {
+		switch e.Kind {
+		case OnlyLeft:
+			diagL = diagL + describe(e.Path, e.Left)
+		case OnlyRight:
+			diagR = diagR + describe(e.Path, e.Right)
+		case Changed:
+			if len(e.Attrs) > 0 {
+				diagC = diagC + fmt.Sprintf("%s: %s differ\n", e.Path, strings.Join(e.Attrs, ", "))
+			} else {
+				diagC = diagC + fmt.Sprintf("%s: differs\n", e.Path)
+			}
+			diagC = diagC + "  left:  " + describe(e.Path, e.Left)
+			diagC = diagC + "  right: " + describe(e.Path, e.Right)
+			for _, line := range strings.SplitAfter(e.Content, "\n") {
+				if line != "" {
+					diagC = diagC + "    " + line
+				}
+			}
+		}
+	}
+
+	if diagL != "" {
+		diags = append(diags, fmt.Sprintf("Unique items from \"%s\": \n", dr.Left)+diagL)
+	}
+	if diagR != "" {
+		diags = append(diags, fmt.Sprintf("Unique items from \"%s\": \n", dr.Right)+diagR)
+	}
+	if diagC != "" {
+		diags = append(diags, fmt.Sprintf("Changed items between \"%s\" and \"%s\": \n", dr.Left, dr.Right)+diagC)
+	}
+
+	return diags
+}
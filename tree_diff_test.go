@@ -6,12 +6,13 @@ package fst // import "go.didenko.com/fst"
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
 type DiffCase struct {
 	dir   string
-	comps []FileRank
+	ranks []Rank
 }
 
 func TestTreeDiff(t *testing.T) {
@@ -22,32 +23,32 @@ func TestTreeDiff(t *testing.T) {
 	FileDelAll(t, ".", "delete.me")
 
 	successes := []DiffCase{
-		{"a_same_content", []FileRank{ByName, ByDir, BySize, ByContent(t)}},
-		{"d_same_empty", []FileRank{ByName, BySize}},
-		{"e_same_empty_subdir", []FileRank{ByName, BySize}},
-		{"k_same_size", []FileRank{ByName, BySize}},
-		{"j_diff_sizes_same_perm", []FileRank{ByName, ByPerm}},
-		{"l_perms_same", []FileRank{ByName, ByPerm}},
+		{"a_same_content", []Rank{DirRank, SizeRank, ContentRank(t)}},
+		{"d_same_empty", []Rank{SizeRank}},
+		{"e_same_empty_subdir", []Rank{SizeRank}},
+		{"k_same_size", []Rank{SizeRank}},
+		{"j_diff_sizes_same_perm", []Rank{PermRank}},
+		{"l_perms_same", []Rank{PermRank}},
 	}
 
 	fails := []DiffCase{
-		{"b_left_nodir", []FileRank{ByName}},
-		{"b_right_nodir", []FileRank{ByName}},
-		{"c_left_nofile", []FileRank{ByName}},
-		{"c_right_nofile", []FileRank{ByName}},
-		{"f_dir_left_file_right", []FileRank{ByName, ByDir}},
-		{"f_dir_right_file_left", []FileRank{ByName, ByDir}},
-		{"g_empty_left", []FileRank{ByName}},
-		{"g_empty_right", []FileRank{ByName}},
-		{"h_diff_content_bin", []FileRank{ByName, ByContent(t)}},
-		{"i_diff_content_text_eol", []FileRank{ByName, ByContent(t)}},
-		{"j_diff_sizes_same_perm", []FileRank{ByName, BySize}},
-		{"l_perms_same", []FileRank{ByName, ByPerm, BySize}},
+		{"b_left_nodir", []Rank{}},
+		{"b_right_nodir", []Rank{}},
+		{"c_left_nofile", []Rank{}},
+		{"c_right_nofile", []Rank{}},
+		{"f_dir_left_file_right", []Rank{DirRank}},
+		{"f_dir_right_file_left", []Rank{DirRank}},
+		{"g_empty_left", []Rank{}},
+		{"g_empty_right", []Rank{}},
+		{"h_diff_content_bin", []Rank{ContentRank(t)}},
+		{"i_diff_content_text_eol", []Rank{ContentRank(t)}},
+		{"j_diff_sizes_same_perm", []Rank{SizeRank}},
+		{"l_perms_same", []Rank{PermRank, SizeRank}},
 	}
 
 	for _, tc := range successes {
 
-		diffs := TreeDiff(t, filepath.Join(tc.dir, "a"), filepath.Join(tc.dir, "b"), tc.comps...)
+		diffs := TreeDiff(t, filepath.Join(tc.dir, "a"), filepath.Join(tc.dir, "b"), tc.ranks...)
 
 		if diffs != nil {
 			t.Errorf("Equivalent directories in \"%s\" tested as different: %v\n", tc.dir, diffs)
@@ -56,7 +57,7 @@ func TestTreeDiff(t *testing.T) {
 
 	for _, tc := range fails {
 
-		diffs := TreeDiff(t, filepath.Join(tc.dir, "a"), filepath.Join(tc.dir, "b"), tc.comps...)
+		diffs := TreeDiff(t, filepath.Join(tc.dir, "a"), filepath.Join(tc.dir, "b"), tc.ranks...)
 
 		if diffs == nil {
 			t.Errorf("Differing directories in \"%s\" passed as equivalent\n", tc.dir)
@@ -83,21 +84,161 @@ func TestTreeDiffTimes(t *testing.T) {
 	_, cleanup := TempCreateChdir(t, nodes)
 	defer cleanup()
 
-	diffs := TreeDiff(t, "a_same_times/a", "a_same_times/b", ByName, ByTime)
+	diffs := TreeDiff(t, "a_same_times/a", "a_same_times/b", TimeRank)
 
 	if diffs != nil {
 		t.Errorf("Equivalent directories in \"%s\" tested as different: %v\n", "a_same_times", diffs)
 	}
 
-	diffs = TreeDiff(t, "b_diff_time_file/a", "b_diff_time_file/b", ByName, ByTime)
+	diffs = TreeDiff(t, "b_diff_time_file/a", "b_diff_time_file/b", TimeRank)
 
 	if diffs == nil {
 		t.Errorf("Differing directories in \"%s\" passed as equivalent\n", "b_diff_time_file")
 	}
 
-	diffs = TreeDiff(t, "c_diff_time_dir/a", "c_diff_time_dir/b", ByName, ByTime)
+	diffs = TreeDiff(t, "c_diff_time_dir/a", "c_diff_time_dir/b", TimeRank)
 
 	if diffs == nil {
 		t.Errorf("Differing directories in \"%s\" passed as equivalent\n", "c_diff_time_dir")
 	}
 }
+
+func TestTreeDiffSymlinks(t *testing.T) {
+
+	nodes := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/f", body: "f"},
+		&Node{name: "a/l", link: "f"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/f", body: "f"},
+		&Node{name: "b/l", link: "f"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "c/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "c/f", body: "f"},
+		&Node{name: "c/l", link: "g"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "d/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "d/f", body: "f"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "d/l", body: "f"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	diffs := TreeDiff(t, "a", "b", DirRank, SymlinkTargetRank, TimeRank)
+	if diffs != nil {
+		t.Errorf("Equivalent directories with symlinks tested as different: %v\n", diffs)
+	}
+
+	diffs = TreeDiff(t, "a", "c", DirRank, SymlinkTargetRank)
+	if diffs == nil {
+		t.Errorf("Symlinks with different targets passed as equivalent\n")
+	}
+
+	if len(diffs) > 0 && !strings.Contains(diffs[0], "link: f") {
+		t.Errorf("Symlink target is not reported: %v\n", diffs)
+	}
+
+	diffs = TreeDiff(t, "a", "d", DirRank, SymlinkTargetRank)
+	if diffs == nil {
+		t.Errorf("A symlink and a regular file passed as equivalent\n")
+	}
+}
+
+func TestTreeCompare(t *testing.T) {
+
+	nodes := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/same", body: "s"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/size", body: "s"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/left", body: "l"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/same", body: "s"},
+		&Node{perm: 0640, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/size", body: "ss"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/right", body: "r"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	result := TreeCompare(t, "a", "b", DirRank, SizeRank, PermRank)
+
+	if result.Equal() {
+		t.Fatal("Differing directories compared as equal")
+	}
+
+	expected := []struct {
+		path  string
+		kind  DiffKind
+		attrs string
+	}{
+		{"left", OnlyLeft, ""},
+		{"right", OnlyRight, ""},
+		{"size", Changed, "size,perm"},
+	}
+
+	if len(result.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %v", len(expected), len(result.Entries), result.Strings())
+	}
+
+	for i, e := range result.Entries {
+		if e.Path != expected[i].path || e.Kind != expected[i].kind || strings.Join(e.Attrs, ",") != expected[i].attrs {
+			t.Errorf("Expected %q %v [%s], got %q %v %v", expected[i].path, expected[i].kind, expected[i].attrs, e.Path, e.Kind, e.Attrs)
+		}
+		if (e.Left == nil) != (e.Kind == OnlyRight) || (e.Right == nil) != (e.Kind == OnlyLeft) {
+			t.Errorf("Unexpected sides for %q: %v, %v", e.Path, e.Left, e.Right)
+		}
+	}
+
+	if len(result.Only(Changed)) != 1 {
+		t.Errorf("Expected one changed entry, got %v", result.Only(Changed))
+	}
+
+	if len(result.Strings()) != 3 {
+		t.Errorf("Expected notes for both trees and changes, got %v", result.Strings())
+	}
+
+	diffs := TreeDiff(t, "a", "b", DirRank, SizeRank, PermRank)
+	if len(diffs) != 3 || !strings.Contains(diffs[2], "size: size, perm differ\n") {
+		t.Errorf("Expected differing attributes in the notes, got %v", diffs)
+	}
+}
+
+func TestTreeComparePaths(t *testing.T) {
+
+	nodes := []*Node{
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/x/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/x/f", body: "aa"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/y/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "a/y/f", body: "b"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/x/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/x/f", body: "b"},
+		&Node{perm: 0700, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/y/"},
+		&Node{perm: 0600, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "b/y/f", body: "aa"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	// Same-named files with swapped content are paired by
+	// their paths, not by the comparators' order
+	result := TreeCompare(t, "a", "b", SizeRank, ContentRank(t))
+
+	changed := result.Only(Changed)
+	if len(changed) != 2 || len(result.Entries) != 2 {
+		t.Fatalf("Expected two changed entries, got %v", result.Strings())
+	}
+
+	for i, rel := range []string{filepath.Join("x", "f"), filepath.Join("y", "f")} {
+		if changed[i].Path != rel || strings.Join(changed[i].Attrs, ",") != "size,content" {
+			t.Errorf("Expected %q to differ in size and content, got %q %v", rel, changed[i].Path, changed[i].Attrs)
+		}
+	}
+
+	diffs := TreeDiff(t, "a", "b", SizeRank, ContentRank(t))
+	if len(diffs) != 1 || !strings.Contains(diffs[0], "name: "+filepath.Join("x", "f")) {
+		t.Errorf("Expected the relative path in the notes, got %v", diffs)
+	} else if !strings.Contains(diffs[0], filepath.Join("y", "f")+": size, content differ\n") {
+		t.Errorf("Expected differing attributes in the notes, got %v", diffs)
+	}
+}
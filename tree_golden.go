@@ -0,0 +1,117 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GoldenUpdate makes TreeGolden update golden trees instead
+// of comparing them. It is set when tests run with the
+// FST_UPDATE environment variable set to a non-empty value.
+// The package does not register command line flags, yet a
+// test package can bind it to one of its own, like:
+//
+//	func init() {
+//		flag.BoolVar(&fst.GoldenUpdate, "fst.update", fst.GoldenUpdate, "update golden trees")
+//	}
+var GoldenUpdate = os.Getenv("FST_UPDATE") != ""
+
+// TreeGolden compares the actual directory tree with the golden
+// one and calls f.Fatalf with the TreeDiff notes if they differ.
+// The golden tree is either a directory, or a tree description
+// file in the ParseReader format, if the golden path is not an
+// existing directory.
+//
+// When GoldenUpdate is set, TreeGolden does not
+// compare the trees, but replaces the golden tree with
+// the actual one. A golden directory's content is replaced by
+// a TreeCopy of the actual tree. A golden file is overwritten
+// with the actual tree's snapshot, written by WriteNodes, which
+// keeps permissions and timestamps.
+func TreeGolden(f Fatalfable, actual, golden string, ranks ...Rank) {
+
+	fi, err := os.Stat(golden)
+	isDir := err == nil && fi.IsDir()
+
+	if GoldenUpdate {
+		if isDir {
+			updateGoldenDir(f, actual, golden)
+		} else {
+			updateGoldenFile(f, actual, golden)
+		}
+		return
+	}
+
+	expected := golden
+
+	if !isDir {
+		root, cleanup := TempInitDir(f)
+		defer cleanup()
+
+		TreeCreate(f, rebase(ParseFile(f, golden), root))
+		expected = root
+	}
+
+	diffs := TreeDiff(f, expected, actual, ranks...)
+	if diffs != nil {
+		f.Fatalf("Tree %q differs from the golden %q, set FST_UPDATE=1 to update it:\n%s", actual, golden, strings.Join(diffs, "\n"))
+	}
+}
+
+func updateGoldenDir(f Fatalfable, actual, golden string) {
+
+	items, err := ioutil.ReadDir(golden)
+	if err != nil {
+		f.Fatalf("Reading the golden directory %q: %s", golden, err)
+	}
+
+	for _, item := range items {
+		err = os.RemoveAll(filepath.Join(golden, item.Name()))
+		if err != nil {
+			f.Fatalf("Removing from the golden directory %q: %s", golden, err)
+		}
+	}
+
+	TreeCopy(f, actual, golden)
+}
+
+func updateGoldenFile(f Fatalfable, actual, golden string) {
+
+	out, err := os.Create(golden)
+	if err != nil {
+		f.Fatalf("Creating the golden file %q: %s", golden, err)
+	}
+
+	WriteNodes(f, out, TreeSnapshot(f, actual))
+
+	err = out.Close()
+	if err != nil {
+		f.Fatalf("Closing the golden file %q: %s", golden, err)
+	}
+}
+
+// rebase makes copies of nodes with names and hard link
+// paths moved into the root directory
+func rebase(nodes []*Node, root string) []*Node {
+
+	moved := make([]*Node, len(nodes))
+
+	for i, n := range nodes {
+		m := *n
+		m.name = filepath.Join(root, n.name)
+		if strings.HasSuffix(n.name, "/") {
+			m.name = m.name + "/"
+		}
+		if n.hard != "" {
+			m.hard = filepath.Join(root, n.hard)
+		}
+		moved[i] = &m
+	}
+
+	return moved
+}
@@ -0,0 +1,57 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestTreeGolden(t *testing.T) {
+
+	nodes := []*Node{
+		{perm: 0750, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "actual/"},
+		{perm: 0750, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "actual/dir/"},
+		{perm: 0640, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "actual/dir/text", body: "two\nlines\n"},
+		{perm: 0600, time: Rfc3339(t, "2004-01-01T01:01:01Z"), name: "actual/bin", body: "\x00\xff"},
+		{perm: 0750, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "golden/"},
+		{perm: 0750, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "golden/stale/"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	ranks := []Rank{DirRank, SizeRank, PermRank, TimeRank, ContentRank(t)}
+
+	defer func(update bool) { GoldenUpdate = update }(GoldenUpdate)
+	GoldenUpdate = false
+
+	for _, golden := range []string{"golden", "golden.fst"} {
+
+		fr := &fatalRecorder{}
+		TreeGolden(fr, "actual", golden, ranks...)
+		if len(fr.msgs) == 0 {
+			t.Errorf("Stale golden %q matched the actual tree", golden)
+		}
+
+		GoldenUpdate = true
+		TreeGolden(t, "actual", golden, ranks...)
+		GoldenUpdate = false
+
+		TreeGolden(t, "actual", golden, ranks...)
+
+		err := ioutil.WriteFile("actual/dir/text", []byte("changed"), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fr = &fatalRecorder{}
+		TreeGolden(fr, "actual", golden, ranks...)
+		if len(fr.msgs) == 0 {
+			t.Errorf("Updated golden %q matched the changed actual tree", golden)
+		}
+
+		TreeCreate(t, nodes[2:3])
+	}
+}
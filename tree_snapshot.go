@@ -0,0 +1,94 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// TreeSnapshot walks the directory and collects nodes
+// describing regular files, directories, and links inside
+// it, with names relative to the directory, with files'
+// content, with access times where available, and with user
+// extended attributes. Other kinds
+// of filesystem objects are skipped. The resulting nodes can
+// be written out with WriteNodes, or be fed into TreeCreate
+// to recreate the tree elsewhere.
+//
+// Regular files with more than one hard link, which are found
+// in the tree more than once, are recorded as hard links to
+// the first found path.
+func TreeSnapshot(f Fatalfable, dir string) []*Node {
+
+	root := filepath.Clean(dir)
+	nodes := make([]*Node, 0)
+	seen := make(map[fileID]string)
+
+	err := filepath.Walk(
+		root,
+		func(fn string, fi os.FileInfo, er error) error {
+
+			if er != nil || fn == root {
+				return er
+			}
+
+			rel, err := filepath.Rel(root, fn)
+			if err != nil {
+				return err
+			}
+
+			n := &Node{perm: attrMode(fi.Mode()), time: fi.ModTime(), name: filepath.ToSlash(rel)}
+
+			switch {
+			case fi.IsDir():
+				n.name = n.name + "/"
+
+			case fi.Mode()&os.ModeSymlink != 0:
+				n.link, err = os.Readlink(fn)
+				if err != nil {
+					return err
+				}
+
+			case fi.Mode().IsRegular():
+				if id, nlink, ok := inode(fi); ok && nlink > 1 {
+					if first, found := seen[id]; found {
+						n.hard = first
+						break
+					}
+					seen[id] = n.name
+				}
+
+				body, err := ioutil.ReadFile(fn)
+				if err != nil {
+					return err
+				}
+				n.body = string(body)
+
+			default:
+				return nil
+			}
+
+			if n.link == "" && n.hard == "" {
+				if atime, _, ok := statTimes(fi); ok {
+					n.atime = atime
+				}
+
+				n.xattrs, err = fileXattrs(fn)
+				if err != nil {
+					return err
+				}
+			}
+
+			nodes = append(nodes, n)
+			return nil
+		})
+
+	if err != nil {
+		f.Fatalf("Taking a snapshot of the tree %q: %s", dir, err)
+	}
+
+	return nodes
+}
@@ -0,0 +1,57 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTreeSnapshotRoundTrip(t *testing.T) {
+
+	nodes := []*Node{
+		{perm: 0750, time: Rfc3339(t, "2001-01-01T01:01:01.123456789Z"), name: "src/"},
+		{perm: 0700, time: Rfc3339(t, "2002-01-01T01:01:01Z"), name: "src/has\ttab/"},
+		{perm: 0640, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/has\ttab/text", body: "two\nlines\nEOF\n"},
+		{perm: 0640, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/0644", body: "-> not a link"},
+		{perm: 0640, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/#%[odd", body: " spaced "},
+		{perm: 0640, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/\"quoted\"", body: "\"quoted\""},
+		{perm: 0600, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/bin", body: "\x00\x01binary\xff\xfe"},
+		{perm: 0600, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/long_bin", body: strings.Repeat("\xff\x00", 100)},
+		{perm: 0600, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/crlf", body: "one\r\ntwo\r\n"},
+		{perm: 0600, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/base64:x", body: "hex:00"},
+		{perm: 0600, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/empty"},
+		{perm: 0600, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/long_line", body: strings.Repeat("x", 100<<10)},
+		{perm: 0600, time: Rfc3339(t, "2003-01-01T01:01:01Z"), name: "src/long_lines", body: strings.Repeat("y", 100<<10) + "\n" + strings.Repeat("z", 100<<10) + "\n"},
+		{name: "src/link", link: "has\ttab/text"},
+		{name: "src/hard", hard: "src/bin"},
+		{perm: 0750, time: Rfc3339(t, "2001-01-01T01:01:01Z"), name: "dst/"},
+	}
+
+	_, cleanup := TempCreateChdir(t, nodes)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	WriteNodes(t, &buf, TreeSnapshot(t, "src"))
+
+	err := os.Chdir("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	TreeCreate(t, ParseReader(t, bytes.NewReader(buf.Bytes())))
+
+	err = os.Chdir("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := TreeDiff(t, "src", "dst", DirRank, SymlinkTargetRank, SizeRank, PermRank, TimeRank, HardLinksRank, ContentRank(t))
+
+	if diffs != nil {
+		t.Errorf("Tree changed in a snapshot round trip: %v\nsnapshot:\n%s", diffs, buf.String())
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	txtarMeta      = "fst:"
+	txtarMarker    = "-- "
+	txtarMarkerEnd = " --"
+)
+
+// txtarFile is a file section of a txtar archive
+type txtarFile struct {
+	name, data string
+}
+
+// splitTxtar splits a txtar archive into its comment and file
+// sections, same as the golang.org/x/tools/txtar package does
+func splitTxtar(data string) (string, []*txtarFile) {
+
+	comment, name, data := txtarNext(data)
+	files := make([]*txtarFile, 0)
+
+	for name != "" {
+		file := &txtarFile{name: name}
+		file.data, name, data = txtarNext(data)
+		files = append(files, file)
+	}
+
+	return comment, files
+}
+
+// txtarNext finds the next file marker in the data and
+// returns the data before it, the marked file name, and
+// the data after the marker line
+func txtarNext(data string) (string, string, string) {
+
+	i := 0
+	for {
+		if name, after := txtarMarkerName(data[i:]); name != "" {
+			return data[:i], name, after
+		}
+
+		j := strings.Index(data[i:], "\n"+txtarMarker)
+		if j < 0 {
+			return withNewline(data), "", ""
+		}
+		i += j + 1
+	}
+}
+
+func txtarMarkerName(data string) (string, string) {
+
+	if !strings.HasPrefix(data, txtarMarker) {
+		return "", ""
+	}
+
+	line, after := data, ""
+	if i := strings.IndexByte(data, '\n'); i >= 0 {
+		line, after = data[:i], data[i+1:]
+	}
+
+	if !strings.HasSuffix(line, txtarMarkerEnd) || len(line) < len(txtarMarker)+len(txtarMarkerEnd) {
+		return "", ""
+	}
+
+	return strings.TrimSpace(line[len(txtarMarker) : len(line)-len(txtarMarkerEnd)]), after
+}
+
+func withNewline(data string) string {
+	if data == "" || strings.HasSuffix(data, "\n") {
+		return data
+	}
+	return data + "\n"
+}
+
+// ParseTxtar reads a txtar archive with the parser's settings.
+// See the ParseTxtar function for the conventions.
+func (p Parser) ParseTxtar(f Fatalfable, archive io.Reader) []*Node {
+
+	data, err := ioutil.ReadAll(archive)
+	if err != nil {
+		f.Fatalf("Reading the txtar archive: %q", err)
+	}
+
+	if p.Now.IsZero() {
+		p.Now = time.Now()
+	}
+
+	comment, files := splitTxtar(string(data))
+
+	// Other comment lines are blanked to keep line numbers
+	// in parsing diagnostics
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, txtarMeta) {
+			lines[i] = line[len(txtarMeta):]
+		} else {
+			lines[i] = ""
+		}
+	}
+
+	dflt := &defaults{}
+
+	records, err := p.parseWith(strings.NewReader(strings.Join(lines, "\n")), dflt)
+	if err != nil {
+		f.Fatalf("While parsing the txtar archive metadata:\n%s", err)
+	}
+
+	listed := make(map[string]*Node)
+	for _, n := range records {
+		listed[n.name] = n
+	}
+
+	unlisted := make([]*Node, 0)
+
+	for _, file := range files {
+
+		if strings.HasSuffix(file.name, "/") {
+			f.Fatalf("The txtar archive file name %q ends with a slash", file.name)
+		}
+
+		n, ok := listed[file.name]
+		if !ok {
+			n = &Node{perm: 0644, time: p.Now, name: file.name, body: file.data}
+			if dflt.hasTime {
+				n.time = dflt.time
+			}
+			if dflt.hasPerm {
+				n.perm = dflt.perm
+			}
+			unlisted = append(unlisted, n)
+			continue
+		}
+
+		if n.body != "" || n.from != "" || n.link != "" || n.hard != "" {
+			f.Fatalf("The txtar archive file %q has both a section and content in its metadata", file.name)
+		}
+		n.body = file.data
+	}
+
+	nodes := make([]*Node, 0, len(records)+len(unlisted))
+	added := make(map[string]bool)
+
+	var add func(n *Node)
+	add = func(n *Node) {
+
+		if added[n.name] {
+			return
+		}
+		added[n.name] = true
+
+		for i := 0; i < len(n.name)-1; i++ {
+
+			if n.name[i] != '/' || added[n.name[:i+1]] {
+				continue
+			}
+
+			if dir, ok := listed[n.name[:i+1]]; ok {
+				add(dir)
+				continue
+			}
+
+			dir := &Node{perm: 0755, time: p.Now, name: n.name[:i+1]}
+			if dflt.hasTime {
+				dir.time = dflt.time
+			}
+			if dflt.hasDirPerm {
+				dir.perm = dflt.dirPerm
+			}
+
+			added[dir.name] = true
+			nodes = append(nodes, dir)
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	for _, n := range records {
+		add(n)
+	}
+
+	for _, n := range unlisted {
+		add(n)
+	}
+
+	return nodes
+}
+
+// ParseFileTxtar reads the named txtar archive file with
+// the parser's settings. Unless the parser's Dir is set,
+// relative "file:" content references and included files
+// are resolved against the archive file's directory.
+func (p Parser) ParseFileTxtar(f Fatalfable, path string) []*Node {
+
+	archive, err := os.Open(path)
+	if err != nil {
+		f.Fatalf("Opening the txtar archive %q: %q", path, err)
+	}
+	defer archive.Close()
+
+	dir := p.Dir
+
+	err = p.enter(path)
+	if err != nil {
+		f.Fatalf("Parsing the txtar archive %q: %q", path, err)
+	}
+
+	if dir != "" {
+		p.Dir = dir
+	}
+
+	return p.ParseTxtar(f, archive)
+}
+
+// ParseTxtar reads a txtar archive, as used by the Go tools
+// for test fixtures, into a list of filesystem node data.
+// Files in the archive become regular files with the archive
+// content.
+//
+// Lines of the archive comment, which start with "fst:", are
+// parsed as lines in the ParseReader format with the "fst:"
+// prefix removed. Other comment lines are ignored. The parsed
+// records provide time and permissions for files and
+// directories with the same names, or describe additional
+// filesystem objects, like links, empty directories, or files
+// with content not fitting the txtar format. Records for files
+// with archive sections can not have content.
+//
+// Files without records get their time and permissions from
+// the %time and %perm directives, or the current time and
+// 0644 permissions if the directives are absent. Parent
+// directories without records are created as needed with
+// the time from the %time directive and permissions from the
+// %dirperm directive, or 0755 if it is absent. Records of
+// directories should precede records of files in them.
+func ParseTxtar(f Fatalfable, archive io.Reader) []*Node {
+	return Parser{}.ParseTxtar(f, archive)
+}
@@ -0,0 +1,110 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTxtar(t *testing.T) {
+
+	archive := `A free text comment is ignored.
+fst: %time 2001-01-01T01:01:01Z
+fst: %perm 0600
+fst: %dirperm 0700
+fst: 2002-02-02T02:02:02Z	0750	conf/
+fst: 0640	conf/app.toml
+fst: conf/empty/
+fst: conf/link	-> app.toml
+fst: bin	base64:AAFiaW5hcnn//g==
+-- conf/app.toml --
+key = "value"
+-- deep/nested/file.txt --
+no metadata
+-- empty --
+`
+
+	expect := []tcase{
+		{Rfc3339(t, "2002-02-02T02:02:02Z"), 0750, "conf", ""},
+		{Rfc3339(t, "2001-01-01T01:01:01Z"), 0640, "conf/app.toml", "key = \"value\"\n"},
+		{Rfc3339(t, "2001-01-01T01:01:01Z"), 0700, "conf/empty", ""},
+		{Rfc3339(t, "2001-01-01T01:01:01Z"), 0600, "bin", "\x00\x01binary\xff\xfe"},
+		{Rfc3339(t, "2001-01-01T01:01:01Z"), 0700, "deep", ""},
+		{Rfc3339(t, "2001-01-01T01:01:01Z"), 0700, "deep/nested", ""},
+		{Rfc3339(t, "2001-01-01T01:01:01Z"), 0600, "deep/nested/file.txt", "no metadata\n"},
+		{Rfc3339(t, "2001-01-01T01:01:01Z"), 0600, "empty", ""},
+	}
+
+	_, cleanup := TempCreateChdir(t, ParseTxtar(t, strings.NewReader(archive)))
+	defer cleanup()
+
+	for _, tc := range expect {
+		fi, err := os.Stat(tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match(t, &tc, fi)
+	}
+
+	if target, err := os.Readlink("conf/link"); err != nil || target != "app.toml" {
+		t.Errorf("Expected the \"conf/link\" symlink to \"app.toml\", got %q, %v", target, err)
+	}
+
+	bad := []string{
+		"fst: 2001-01-01T01:01:01Z\t0600\tf\tcontent\n-- f --\ncontent\n",
+		"fst: bad line\n",
+		"-- dir/ --\n",
+	}
+
+	for _, archive := range bad {
+		fr := &fatalRecorder{}
+		Parser{Now: time.Now()}.ParseTxtar(fr, strings.NewReader(archive))
+		if len(fr.msgs) == 0 {
+			t.Errorf("Malformed txtar archive passed parsing: %q", archive)
+		}
+	}
+}
+
+func TestWriteTxtar(t *testing.T) {
+
+	tree := "2001-01-01T01:01:01Z\t0750\tsrc/\n" +
+		"2001-01-01T01:01:01Z\t0700\tsrc/empty/\n" +
+		"2002-01-01T01:01:01Z\t0640\tsrc/text\t\"two\\nlines\\n\"\n" +
+		"2002-01-01T01:01:01Z\t0640\tsrc/no_eol\tone line\n" +
+		"2002-01-01T01:01:01Z\t0600\tsrc/bin\tbase64:AAFiaW5hcnn//g==\n" +
+		"2002-01-01T01:01:01Z\t0600\tsrc/marker\t\"-- fake --\\n\"\n" +
+		"2002-01-01T01:01:01Z\t0600\tsrc/\\\"quoted\\\"\t\"\\n\"\n" +
+		"2002-01-01T01:01:01Z\t0600\tsrc/has\\ttab\t\"\"\n" +
+		"2001-01-01T01:01:01Z\t0777\tsrc/link\t-> text\n" +
+		"2001-01-01T01:01:01Z\t0777\tsrc/hard\t=> src/text\n" +
+		"2001-01-01T01:01:01Z\t0750\tdst/\n"
+
+	_, cleanup := TempCreateChdir(t, ParseReader(t, strings.NewReader(tree)))
+	defer cleanup()
+
+	var archive bytes.Buffer
+	WriteTxtar(t, &archive, "src")
+
+	err := os.Chdir("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	TreeCreate(t, ParseTxtar(t, bytes.NewReader(archive.Bytes())))
+
+	err = os.Chdir("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := TreeDiff(t, "src", "dst", DirRank, SymlinkTargetRank, SizeRank, PermRank, TimeRank, HardLinksRank, ContentRank(t))
+
+	if diffs != nil {
+		t.Errorf("Tree changed in a txtar round trip: %v\narchive:\n%s", diffs, archive.String())
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// inTxtar tells if the node's content can be stored
+// as a txtar archive file section
+func inTxtar(n *Node) bool {
+
+	if n.link != "" || n.hard != "" || n.from != "" ||
+		strings.HasSuffix(n.name, "/") ||
+		strings.ContainsAny(n.name, "\n\r") || strings.TrimSpace(n.name) != n.name ||
+		!utf8.ValidString(n.body) || withNewline(n.body) != n.body {
+		return false
+	}
+
+	for _, line := range strings.Split(n.body, "\n") {
+		if name, _ := txtarMarkerName(line); name != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WriteTxtar writes the content of the directory as a txtar
+// archive, which ParseTxtar reads back into the same tree.
+// Text files are stored as archive files. Every filesystem
+// object has a metadata record in the archive comment, which
+// also holds content of files not fitting the txtar format,
+// like binary files or files without a trailing newline.
+func WriteTxtar(f Fatalfable, w io.Writer, dir string) {
+
+	nodes := TreeSnapshot(f, dir)
+
+	var comment, files strings.Builder
+
+	for _, n := range nodes {
+
+		section := inTxtar(n)
+
+		comment.WriteString(txtarMeta + " " + formatRecord(n, section, false) + "\n")
+		for _, line := range formatXattrs(n) {
+			comment.WriteString(txtarMeta + " " + line + "\n")
+		}
+
+		if section {
+			files.WriteString(txtarMarker + n.name + txtarMarkerEnd + "\n" + n.body)
+		}
+	}
+
+	_, err := io.WriteString(w, comment.String()+files.String())
+	if err != nil {
+		f.Fatalf("Writing the txtar archive of %q: %q", dir, err)
+	}
+}
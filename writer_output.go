@@ -0,0 +1,168 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// markers are prefixes, which make fields special in the
+// ParseReader format, so that fields starting with them
+// should be quoted to be taken literally
+var markers = []string{
+	"\"", "`", commentMark, directiveMark, "[",
+	linkMark, hardMark, heredocMark, fileMark,
+}
+
+// quoteField quotes a name or a content for the ParseReader
+// format, if it would not be read back literally otherwise
+func quoteField(s string) string {
+
+	if s == "" || !utf8.ValidString(s) ||
+		timeLike.MatchString(s) || numLike.MatchString(s) ||
+		strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+
+	for _, m := range markers {
+		if strings.HasPrefix(s, m) {
+			return strconv.Quote(s)
+		}
+	}
+
+	if i := strings.IndexByte(s, ':'); i > 0 {
+		if _, ok := encodings[s[:i]]; ok {
+			return strconv.Quote(s)
+		}
+	}
+
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+
+	return s
+}
+
+// formatRecord formats the node as a ParseReader record line,
+// without content if the bare flag is set. If the heredocs
+// flag is set, then long and multi-line content is formatted
+// as a heredoc following the record line.
+func formatRecord(n *Node, bare, heredocs bool) string {
+
+	stamp := n.time.Format(time.RFC3339Nano)
+	if !n.atime.IsZero() {
+		stamp = stamp + "," + n.atime.Format(time.RFC3339Nano)
+	}
+
+	fields := []string{stamp, octal(n.perm), quoteField(n.name)}
+
+	switch {
+	case n.link != "":
+		fields = append(fields, linkMark+" "+quoteField(n.link))
+	case n.hard != "":
+		fields = append(fields, hardMark+" "+quoteField(n.hard))
+	case bare || strings.HasSuffix(n.name, "/"):
+	case n.from != "":
+		fields = append(fields, fileMark+quoteField(n.from))
+	case n.body == "":
+	case heredocs && textDoc(n.body):
+		fields = append(fields, heredocMark+formatHeredoc(n.body))
+	case utf8.ValidString(n.body):
+		fields = append(fields, quoteField(n.body))
+	case heredocs && len(n.body) > base64Width:
+		encoded := base64.StdEncoding.EncodeToString([]byte(n.body))
+		lines := make([]string, 0, len(encoded)/base64Width+1)
+		for len(encoded) > base64Width {
+			lines = append(lines, encoded[:base64Width])
+			encoded = encoded[base64Width:]
+		}
+		lines = append(lines, encoded)
+		fields = append(fields, "base64:"+heredocMark+formatHeredoc(strings.Join(lines, "\n")+"\n"))
+	default:
+		fields = append(fields, "base64:"+base64.StdEncoding.EncodeToString([]byte(n.body)))
+	}
+
+	return strings.Join(fields, "\t")
+}
+
+// formatXattrs formats the node's extended attributes as
+// %xattr directive lines, which follow the node's record
+func formatXattrs(n *Node) []string {
+	lines := make([]string, 0, len(n.xattrs))
+	for _, name := range sortedXattrs(n.xattrs) {
+		lines = append(lines, xattrMark+" "+name+" "+quoteField(n.xattrs[name]))
+	}
+	return lines
+}
+
+const base64Width = 76
+
+// textDoc tells if the content is a multi-line text, which
+// reads back from a heredoc exactly
+func textDoc(body string) bool {
+
+	if !strings.Contains(body, "\n") || !strings.HasSuffix(body, "\n") ||
+		strings.ContainsRune(body, '\r') || !utf8.ValidString(body) {
+		return false
+	}
+
+	for _, r := range body {
+		if r != '\n' && r != '\t' && !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// formatHeredoc makes a heredoc terminator, which is not
+// found in the content, and returns it followed by
+// the content and the terminator line
+func formatHeredoc(body string) string {
+
+	lines := strings.Split(body, "\n")
+
+	term := "EOF"
+	for i := 1; ; i++ {
+		clash := false
+		for _, line := range lines {
+			if strings.TrimSpace(line) == term {
+				clash = true
+				break
+			}
+		}
+
+		if !clash {
+			break
+		}
+		term = "EOF" + strconv.Itoa(i)
+	}
+
+	return term + "\n" + body + term
+}
+
+// WriteNodes writes the nodes in the ParseReader format, so
+// that ParseReader reads back the same nodes. Names and
+// content are quoted as needed, multi-line text content is
+// written as heredocs, and content, which is not valid UTF-8,
+// is encoded as base64. Times are written with nanoseconds.
+// Extended attributes are written as %xattr directives.
+func WriteNodes(f Fatalfable, w io.Writer, nodes []*Node) {
+
+	for _, n := range nodes {
+		lines := append([]string{formatRecord(n, false, true)}, formatXattrs(n)...)
+		_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+		if err != nil {
+			f.Fatalf("Writing the %q node record: %q", n.name, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// xattrPrefix is the namespace of extended attributes, which
+// are set, copied, and compared by the package
+const xattrPrefix = "user."
+
+var errXattrUnsupported = errors.New("extended attributes are not supported by the filesystem, see XattrSupported")
+
+// XattrSupported tells if user extended attributes can be set
+// on files in the directory. Functions setting extended
+// attributes fail if they are not supported, so tests relying
+// on the attributes may check it first to skip themselves.
+func XattrSupported(dir string) bool {
+
+	file, err := ioutil.TempFile(dir, "xattr")
+	if err != nil {
+		return false
+	}
+
+	name := file.Name()
+	file.Close()
+	defer os.Remove(name)
+
+	return setXattr(name, xattrPrefix+"fst", "probe") == nil
+}
+
+// xattrName checks that the extended attribute name is
+// in the "user." namespace and has no white space in it
+func xattrName(name string) error {
+	if !strings.HasPrefix(name, xattrPrefix) || len(name) == len(xattrPrefix) {
+		return fmt.Errorf("the extended attribute name %q is not in the %q namespace", name, xattrPrefix)
+	}
+	if strings.ContainsAny(name, " \t\r\n\x00") {
+		return fmt.Errorf("the extended attribute name %q has white space in it", name)
+	}
+	return nil
+}
+
+// sortedXattrs lists the names of extended attributes in
+// sorted order
+func sortedXattrs(attrs map[string]string) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileXattrs collects the file's user extended attributes,
+// treating unsupported attributes as none
+func fileXattrs(path string) (map[string]string, error) {
+	attrs, err := listXattrs(path)
+	if err == errXattrUnsupported {
+		return nil, nil
+	}
+	if err != nil || len(attrs) == 0 {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// userXattrs returns the file's user extended attributes,
+// reading them only once for the FileInfoPath struct
+func (fip *FileInfoPath) userXattrs() (map[string]string, error) {
+	fip.xattrOnce.Do(func() {
+		fip.xattrs, fip.xattrErr = fileXattrs(fip.path)
+	})
+	return fip.xattrs, fip.xattrErr
+}
+
+// copyXattrs copies user extended attributes from the source
+// file to the destination file, if the source filesystem
+// supports them
+func copyXattrs(src, dst string) error {
+
+	attrs, err := fileXattrs(src)
+	if err != nil {
+		return fmt.Errorf("Listing extended attributes of %q: %s", src, err)
+	}
+
+	for _, name := range sortedXattrs(attrs) {
+		err := setXattr(dst, name, attrs[name])
+		if err != nil {
+			return fmt.Errorf("Setting the extended attribute %q on %q: %s", name, dst, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+//go:build linux
+// +build linux
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"strings"
+	"syscall"
+)
+
+// setXattr sets the extended attribute of the file
+func setXattr(path, name, value string) error {
+	err := syscall.Setxattr(path, name, []byte(value), 0)
+	if err == syscall.ENOTSUP {
+		return errXattrUnsupported
+	}
+	return err
+}
+
+// listXattrs collects the file's extended attributes in
+// the "user." namespace
+func listXattrs(path string) (map[string]string, error) {
+
+	names, err := xattrBuffer(func(buf []byte) (int, error) {
+		return syscall.Listxattr(path, buf)
+	})
+	if err == syscall.ENOTSUP {
+		return nil, errXattrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string]string)
+
+	for _, name := range strings.Split(string(names), "\x00") {
+		if !strings.HasPrefix(name, xattrPrefix) {
+			continue
+		}
+
+		value, err := xattrBuffer(func(buf []byte) (int, error) {
+			return syscall.Getxattr(path, name, buf)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		attrs[name] = string(value)
+	}
+
+	return attrs, nil
+}
+
+// xattrBuffer calls the function first to find out the size
+// of the buffer needed, and then to fill the buffer. It retries
+// if the needed size grows in between.
+func xattrBuffer(call func([]byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := call(nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+
+		buf := make([]byte, size)
+		size, err = call(buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return buf[:size], nil
+	}
+}
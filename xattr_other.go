@@ -0,0 +1,17 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+//go:build !linux
+// +build !linux
+
+package fst // import "go.didenko.com/fst"
+
+// setXattr is not supported on the platform
+func setXattr(path, name, value string) error {
+	return errXattrUnsupported
+}
+
+// listXattrs is not supported on the platform
+func listXattrs(path string) (map[string]string, error) {
+	return nil, errXattrUnsupported
+}
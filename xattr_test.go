@@ -0,0 +1,143 @@
+// Copyright 2017-2019 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseReaderXattrs(t *testing.T) {
+
+	tree := `
+%time	2001-01-01T01:01:01Z
+%perm	0700
+dir/
+%xattr user.kind directory
+dir/file	content
+%xattr	user.empty
+%xattr user.spaced " two words "
+`
+
+	nodes := ParseReader(t, strings.NewReader(tree))
+
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %d", len(nodes))
+	}
+
+	if len(nodes[0].xattrs) != 1 || nodes[0].xattrs["user.kind"] != "directory" {
+		t.Errorf("Unexpected directory attributes: %v", nodes[0].xattrs)
+	}
+
+	expected := map[string]string{"user.empty": "", "user.spaced": " two words "}
+	if len(nodes[1].xattrs) != len(expected) {
+		t.Errorf("Unexpected file attributes: %v", nodes[1].xattrs)
+	}
+	for name, value := range expected {
+		if v, ok := nodes[1].xattrs[name]; !ok || v != value {
+			t.Errorf("Expected the %q attribute %q, got %q", name, value, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	WriteJSON(t, &buf, nodes)
+	decoded := ParseJSON(t, &buf)
+	if len(decoded) != 2 || decoded[1].xattrs["user.spaced"] != " two words " || len(decoded[1].xattrs) != 2 {
+		t.Errorf("Extended attributes do not round trip through JSON: %v", decoded)
+	}
+
+	bad := []string{
+		"%xattr user.orphan value",
+		"2001-01-01T01:01:01Z\t0600\tf\n%xattr trusted.x value",
+		"2001-01-01T01:01:01Z\t0600\tf\n%xattr user. value",
+		"2001-01-01T01:01:01Z\t0600\tf\n%xattr user.q \"unbalanced",
+		"l\t-> f\n%xattr user.x value",
+	}
+
+	for _, input := range bad {
+		if _, err := (Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Malformed extended attribute passed parsing: %q", input)
+		}
+	}
+}
+
+func TestTreeXattrs(t *testing.T) {
+
+	root, cleanup := TempInitChdir(t)
+	defer cleanup()
+
+	if !XattrSupported(root) {
+		t.Skip("Extended attributes are not supported in the temporary directory")
+	}
+
+	tree := `
+%time	2001-01-01T01:01:01Z
+%perm	0700
+src/
+%xattr user.kind directory
+src/file	content
+%xattr user.checksum abc
+src/bare	content
+dst/
+`
+
+	TreeCreate(t, ParseReader(t, strings.NewReader(tree)))
+
+	attrs, err := listXattrs("src/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 1 || attrs["user.checksum"] != "abc" {
+		t.Errorf("Unexpected extended attributes of the created file: %v", attrs)
+	}
+
+	TreeCopy(t, "src", "dst")
+
+	if diffs := TreeDiff(t, "src", "dst", XattrRank(t)); diffs != nil {
+		t.Errorf("Extended attributes are not copied: %v", diffs)
+	}
+
+	if err := setXattr("dst/bare", "user.extra", "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	result := TreeCompare(t, "src", "dst", XattrRank(t))
+	if len(result.Entries) != 1 || result.Entries[0].Path != "bare" || strings.Join(result.Entries[0].Attrs, ",") != "xattr" {
+		t.Errorf("Expected only the \"bare\" file attributes to differ, got %v", result.Strings())
+	}
+
+	var buf bytes.Buffer
+	WriteNodes(t, &buf, TreeSnapshot(t, "src"))
+
+	if !strings.Contains(buf.String(), "%xattr user.checksum abc\n") {
+		t.Errorf("Extended attributes are not written:\n%s", buf.String())
+	}
+
+	if err := os.Mkdir("again", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	TreeCreate(t, rebase(ParseReader(t, &buf), "again"))
+
+	if diffs := TreeDiff(t, "src", "again", XattrRank(t)); diffs != nil {
+		t.Errorf("Extended attributes do not round trip: %v", diffs)
+	}
+
+	less := ByXattr(t)
+	fips := MakeFipSlice(t, "src/bare", "dst/bare")
+	if !less(fips[0], fips[1]) {
+		t.Fatal("Files with different extended attributes are not told apart")
+	}
+
+	// The attributes are read once per FileInfoPath struct,
+	// so later changes on the filesystem are not seen
+	if err := setXattr("src/bare", "user.extra", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if !less(fips[0], fips[1]) {
+		t.Error("Extended attributes are read again for the same FileInfoPath struct")
+	}
+}